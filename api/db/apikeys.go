@@ -0,0 +1,147 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// APIKeyPrefix starts every key so they are easy to spot in logs and secrets
+const APIKeyPrefix = "dodle_"
+
+var ErrUnknownAPIKey = errors.New("unknown api key")
+
+func (s *MongoStore) CreateAPIKeysIndexes(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("ApiKeys")
+	if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    map[string]interface{}{"prefix": 1},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("failed to create api keys index: %w", err)
+	}
+	return nil
+}
+
+func randomHex(size int) (string, error) {
+	raw := make([]byte, size)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// NewAPIKey mints a key. The returned plain key is never stored and cannot
+// be shown again; the APIKey only keeps its hash.
+func NewAPIKey(request persons.APIKeyRequest) (string, persons.APIKey, error) {
+	prefix, err := randomHex(4)
+	if err != nil {
+		return "", persons.APIKey{}, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", persons.APIKey{}, fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key := APIKeyPrefix + prefix + "_" + secret
+
+	return key, persons.APIKey{
+		Name:      request.Name,
+		Prefix:    prefix,
+		Hash:      HashToken(key),
+		Scopes:    request.Scopes,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: request.ExpiresAt,
+	}, nil
+}
+
+func (s *MongoStore) CreateAPIKey(ctx context.Context, request persons.APIKeyRequest) (string, persons.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	key, apiKey, err := NewAPIKey(request)
+	if err != nil {
+		return "", persons.APIKey{}, err
+	}
+
+	result, err := s.database().Collection("ApiKeys").InsertOne(ctx, apiKey)
+	if err != nil {
+		return "", persons.APIKey{}, fmt.Errorf("failed to create api key: %w", err)
+	}
+	apiKey.ID = result.InsertedID.(primitive.ObjectID)
+
+	return key, apiKey, nil
+}
+
+// FindAPIKey looks a key up by the prefix embedded in it. The caller still
+// has to compare the hash.
+func (s *MongoStore) FindAPIKey(ctx context.Context, prefix string) (persons.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var apiKey persons.APIKey
+	err := s.database().Collection("ApiKeys").FindOne(ctx, map[string]interface{}{"prefix": prefix}).Decode(&apiKey)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return persons.APIKey{}, ErrUnknownAPIKey
+	}
+	if err != nil {
+		return persons.APIKey{}, fmt.Errorf("failed to find api key: %w", err)
+	}
+	return apiKey, nil
+}
+
+func (s *MongoStore) TouchAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.database().Collection("ApiKeys").UpdateOne(ctx,
+		map[string]interface{}{"_id": id},
+		map[string]interface{}{"$set": map[string]interface{}{"last_used_at": time.Now().UTC()}})
+	if err != nil {
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return nil
+}
+
+func (s *MongoStore) ListAPIKeys(ctx context.Context) ([]persons.APIKey, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := s.database().Collection("ApiKeys").Find(ctx, map[string]interface{}{},
+		options.Find().SetSort(map[string]interface{}{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find api keys: %w", err)
+	}
+
+	keys := []persons.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("failed to decode api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key for good; it is kept for auditing
+func (s *MongoStore) RevokeAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.database().Collection("ApiKeys").UpdateOne(ctx,
+		map[string]interface{}{"_id": id, "revoked_at": map[string]interface{}{"$exists": false}},
+		map[string]interface{}{"$set": map[string]interface{}{"revoked_at": time.Now().UTC()}})
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrUnknownAPIKey
+	}
+	return nil
+}
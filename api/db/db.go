@@ -3,110 +3,98 @@ package db
 import (
 	persons "api/struct"
 	data "api/utils/data"
+	metrics "api/utils/metrics"
 	"context"
+	"errors"
 	"fmt"
-	"log"
-	"math/rand"
-	"os"
-	"time"
+	"log/slog"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func ConnectToMongoDB() (*mongo.Client, error) {
+func ConnectToMongoDB(ctx context.Context, uri string) (*mongo.Client, error) {
 	// Set client options
-	clientOptions := options.Client().ApplyURI(os.Getenv("MONGODB_URI"))
+	clientOptions := options.Client().ApplyURI(uri).SetMonitor(commandMonitor())
 
 	// Connect to MongoDB
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
 	}
 
 	// Check the connection
-	err = client.Ping(context.TODO(), nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	log.Println("Connected to MongoDB!")
+	slog.Debug("Connected to MongoDB")
 	return client, nil
 }
 
-func CreateDatabase(client *mongo.Client, dbName string) string {
+// commandMonitor measures how long every MongoDB command takes
+func commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			metrics.MongoOperationDuration.WithLabelValues(e.CommandName, metrics.ResultSuccess).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			metrics.MongoOperationDuration.WithLabelValues(e.CommandName, metrics.ResultFailure).Observe(e.Duration.Seconds())
+		},
+	}
+}
+
+func (s *MongoStore) CreateDatabase() string {
 	// Create a database by accessing it
-	db := client.Database(dbName)
+	db := s.database()
 	if db == nil {
 		return "Failed to create database"
 	}
-	fmt.Printf("Database %s created successfully\n", dbName)
+	slog.Debug("Database created", "database", s.dbName)
 	return ""
 }
 
-func CreateCollection(client *mongo.Client, dbName, collectionName string) string {
+func (s *MongoStore) CreateCollection(collectionName string) string {
 	// Create a collection in the specified database
-	collection := client.Database(dbName).Collection(collectionName)
+	collection := s.database().Collection(collectionName)
 	if collection == nil {
 		return "Failed to create collection"
 	}
-	fmt.Printf("Collection %s created in database %s", collectionName, dbName)
+	slog.Debug("Collection created", "collection", collectionName, "database", s.dbName)
 	return ""
 }
 
-func PopulatePersonsCollection(client *mongo.Client, dbName string, personsToInsert persons.Persons) string {
-	// Example function to populate the Persons collection
-	// This is a placeholder; actual implementation would depend on your data model
-	collection := client.Database(dbName).Collection("Persons")
-	if collection == nil {
-		return "Failed to access Persons collection"
-	}
+// GetPersons returns the guessable persons, leaving out soft-deleted ones
+func (s *MongoStore) GetPersons(ctx context.Context) (persons.Persons, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	// Delete existing documents in the collection
-	_, err := collection.DeleteMany(context.TODO(), map[string]interface{}{})
-	if err != nil {
-		return "Failed to delete existing documents: " + err.Error()
-	}
-
-	// Insert persons into the collection
-	personsInterface := []interface{}{}
-	for _, person := range personsToInsert.Persons {
-		personsInterface = append(personsInterface, person)
-	}
-	if len(personsInterface) == 0 {
-		return "No persons to insert"
-	}
-	_, err = collection.InsertMany(context.TODO(), personsInterface)
-	if err != nil {
-		return "Failed to insert persons: " + err.Error()
-	}
-	fmt.Printf("Inserted %d persons into the Persons collection", len(personsInterface))
-	return ""
-}
-
-func GetPersons(client *mongo.Client, dbName string) (persons.Persons, error) {
 	// Retrieve all persons from the Persons collection
-	collection := client.Database(dbName).Collection("Persons")
+	collection := s.database().Collection("Persons")
 	if collection == nil {
 		return persons.Persons{}, nil
 	}
 
-	cursor, err := collection.Find(context.TODO(), map[string]interface{}{})
+	cursor, err := collection.Find(ctx, map[string]interface{}{"deleted_at": map[string]interface{}{"$exists": false}})
 	if err != nil {
 		return persons.Persons{}, err
 	}
 	defer func() {
-		if err := cursor.Close(context.TODO()); err != nil {
-			log.Printf("Failed to close cursor: %v", err)
+		if err := cursor.Close(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to close cursor", "error", err)
 		}
 	}()
 
 	var personsList persons.Persons
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var person persons.Person
 		if err := cursor.Decode(&person); err != nil {
 			return persons.Persons{}, err
 		}
+		person.Migrate()
 		personsList.Persons = append(personsList.Persons, person)
 	}
 
@@ -117,51 +105,61 @@ func GetPersons(client *mongo.Client, dbName string) (persons.Persons, error) {
 	return personsList, nil
 }
 
-func GetPersonOfTheDay(client *mongo.Client, dbName string) (persons.Person, error) {
+// GetPersonOfTheDay returns the person picked for a game day
+func (s *MongoStore) GetPersonOfTheDay(ctx context.Context, date string) (persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Retrieve the person of the day from the GuessesOfTheMonth collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
+	collection := s.database().Collection("GuessesOfTheMonth")
 	if collection == nil {
 		return persons.Person{}, fmt.Errorf("GuessesOfTheMonth collection not found")
 	}
 
-	var currentDate = time.Now().Format("2006-01-02") // Format the date as YYYY-MM-DD
-
 	// Create a document structure to match what's stored in MongoDB
 	var doc struct {
 		Date   string         `bson:"date"`
 		Person persons.Person `bson:"person"`
 	}
 
-	err := collection.FindOne(context.TODO(), map[string]interface{}{"date": currentDate}).Decode(&doc)
+	err := collection.FindOne(ctx, map[string]interface{}{"date": date}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return persons.Person{}, fmt.Errorf("%w for %s", ErrNoPersonOfTheDay, date)
+	}
 	if err != nil {
-		return persons.Person{}, fmt.Errorf("failed to find person of the day: %v", err)
+		return persons.Person{}, fmt.Errorf("failed to find person of the day: %w", err)
 	}
+	doc.Person.Migrate()
 
-	// Log the found person for debugging
-	fmt.Printf("Found Person of the Day (%s): %s %s\n", doc.Date, doc.Person.Firstname, doc.Person.Lastname)
+	// Never the person itself, the logs would give the answer away
+	slog.DebugContext(ctx, "Found person of the day", "date", doc.Date)
 
 	return doc.Person, nil
 }
 
-func GetPersonsOfTheDay(client *mongo.Client, dbName string) ([]persons.Person, error) {
+func (s *MongoStore) GetPersonsOfTheDay(ctx context.Context) ([]persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Retrieve all persons of the day from the GuessesOfTheMonth collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
+	collection := s.database().Collection("GuessesOfTheMonth")
 	if collection == nil {
 		return nil, fmt.Errorf("GuessesOfTheMonth collection not found")
 	}
 
-	cursor, err := collection.Find(context.TODO(), map[string]interface{}{})
+	// Oldest first, the natural order of a collection is not the insertion order
+	cursor, err := collection.Find(ctx, map[string]interface{}{}, options.Find().SetSort(bson.D{{Key: "date", Value: 1}}))
 	if err != nil {
-		return nil, fmt.Errorf("failed to find persons of the day: %v", err)
+		return nil, fmt.Errorf("failed to find persons of the day: %w", err)
 	}
 	defer func() {
-		if err := cursor.Close(context.TODO()); err != nil {
-			log.Printf("Failed to close cursor: %v", err)
+		if err := cursor.Close(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to close cursor", "error", err)
 		}
 	}()
 
 	var personsOfTheDay []persons.Person
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		// Create a document to hold the raw document structure
 		var doc struct {
 			Date   string         `bson:"date"`
@@ -169,94 +167,78 @@ func GetPersonsOfTheDay(client *mongo.Client, dbName string) ([]persons.Person,
 		}
 
 		if err := cursor.Decode(&doc); err != nil {
-			return nil, fmt.Errorf("failed to decode document: %v", err)
+			return nil, fmt.Errorf("failed to decode document: %w", err)
 		}
+		doc.Person.Migrate()
 
 		// Only add the person if it has data
 		if doc.Person.Firstname != "" && doc.Person.Lastname != "" {
-			fmt.Printf("Person of the Day (%s): %s %s\n", doc.Date, doc.Person.Firstname, doc.Person.Lastname)
 			personsOfTheDay = append(personsOfTheDay, doc.Person)
 		} else {
-			fmt.Printf("Warning: Found document for date %s but person data is incomplete\n", doc.Date)
+			slog.DebugContext(ctx, "Person of the day document is incomplete", "date", doc.Date)
 		}
 	}
 
 	if err := cursor.Err(); err != nil {
-		return nil, fmt.Errorf("cursor error: %v", err)
+		return nil, fmt.Errorf("cursor error: %w", err)
 	}
 
 	return personsOfTheDay, nil
 }
 
-func TryGuess(client *mongo.Client, dbName string, guess persons.Person) (bool, persons.Person, error) {
-	// Check if the guess matches the person of the day
-	personOfTheDay, err := GetPersonOfTheDay(client, dbName)
-	if err != nil {
-		return false, persons.Person{}, fmt.Errorf("failed to get person of the day: %v", err)
-	}
+func (s *MongoStore) FindPerson(ctx context.Context, id string) (persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	fmt.Printf("Guess: %s %s, Person of the Day: %s %s\n", guess.Firstname, guess.Lastname, personOfTheDay.Firstname, personOfTheDay.Lastname)
+	collection := s.database().Collection("Persons")
 
-	// Compare the guess with the person of the day
-	if guess.Firstname == personOfTheDay.Firstname && guess.Lastname == personOfTheDay.Lastname {
-		return true, personOfTheDay, nil // Correct guess
+	var person persons.Person
+	err := collection.FindOne(ctx, map[string]interface{}{"_id": id}).Decode(&person)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return persons.Person{}, ErrUnknownPerson
 	}
-
-	var returnedPerson persons.Person
-	if personOfTheDay.Firstname == guess.Firstname {
-		returnedPerson.Firstname = personOfTheDay.Firstname
-	}
-	if personOfTheDay.Lastname == guess.Lastname {
-		returnedPerson.Lastname = personOfTheDay.Lastname
-	}
-	if personOfTheDay.Gender == guess.Gender {
-		returnedPerson.Gender = personOfTheDay.Gender
-	}
-	if personOfTheDay.Workplace == guess.Workplace {
-		returnedPerson.Workplace = personOfTheDay.Workplace
-	}
-	if personOfTheDay.Type == guess.Type {
-		returnedPerson.Type = personOfTheDay.Type
-	}
-	if personOfTheDay.Image == guess.Image {
-		returnedPerson.Image = personOfTheDay.Image
-	}
-	if personOfTheDay.Hint == guess.Hint {
-		returnedPerson.Hint = personOfTheDay.Hint
+	if err != nil {
+		return persons.Person{}, fmt.Errorf("failed to find person: %w", err)
 	}
+	person.Migrate()
 
-	return false, returnedPerson, nil // Incorrect guess
+	return person, nil
 }
 
-func CreatePersonOfTheDay(client *mongo.Client, dbName string, person persons.Person) error {
+func (s *MongoStore) CreatePersonOfTheDay(ctx context.Context, date string, person persons.Person) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Create or update the person of the day in the Persons collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
+	collection := s.database().Collection("GuessesOfTheMonth")
 	if collection == nil {
 		return fmt.Errorf("persons collection not found")
 	}
 
-	currentDate := time.Now().Format("2006-01-02") // Format the date as YYYY-MM-DD
-
 	personOfTheDay := map[string]interface{}{
-		"date":   currentDate,
-		"person": person,
+		"date":      date,
+		"person_id": person.ID,
+		"person":    person,
 	}
 
 	// Upsert the person of the day
 	_, err := collection.InsertMany(
-		context.TODO(),
+		ctx,
 		[]interface{}{personOfTheDay},
 	)
 	if err != nil {
-		return fmt.Errorf("failed to create or update person of the day: %v", err)
+		return fmt.Errorf("failed to create or update person of the day: %w", err)
 	}
 
 	return nil
 }
 
-func DeletePersonOfTheDay(client *mongo.Client, dbName string, date string) error {
+func (s *MongoStore) DeletePersonOfTheDay(ctx context.Context, date string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
 	// Delete the person of the day from the Persons collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
+	collection := s.database().Collection("GuessesOfTheMonth")
 	if collection == nil {
 		return fmt.Errorf("persons collection not found")
 	}
@@ -264,151 +246,89 @@ func DeletePersonOfTheDay(client *mongo.Client, dbName string, date string) erro
 	dateFrom := date // Date from one day ago
 
 	// Delete the person of the day for the current date
-	_, err := collection.DeleteOne(context.TODO(), map[string]interface{}{"date": dateFrom})
+	_, err := collection.DeleteOne(ctx, map[string]interface{}{"date": dateFrom})
 	if err != nil {
-		return fmt.Errorf("failed to delete person of the day: %v", err)
+		return fmt.Errorf("failed to delete person of the day: %w", err)
 	}
 
 	return nil
 }
 
-func InitDB(mongoClient *mongo.Client) error {
-	CreateDatabase(mongoClient, "dodle")
-	fmt.Println("Creating collections...")
-	CreateCollection(mongoClient, "dodle", "Persons")
-	CreateCollection(mongoClient, "dodle", "GuessesOfTheMonth")
+// InitDB creates the collections and indexes, then syncs the Persons
+// collection with the persons file
+func InitDB(ctx context.Context, s *MongoStore, personsFile string, dryRun bool) error {
+	s.CreateDatabase()
+	slog.DebugContext(ctx, "Creating collections")
+	s.CreateCollection("Persons")
+	s.CreateCollection("GuessesOfTheMonth")
+	s.CreateCollection("Users")
+	s.CreateCollection("Sessions")
+	s.CreateCollection("GuessesCache")
+	s.CreateCollection("Scores")
+	s.CreateCollection("Locks")
+	s.CreateCollection("Schedule")
+	s.CreateCollection("ApiKeys")
+	s.CreateCollection("RateLimits")
 
-	// Load persons from file
-	persons, err := data.OpenPersonsFile()
-	if err != nil {
-		return fmt.Errorf("failed to open persons file: %v", err)
+	if err := s.CreateUsersIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create users indexes: %w", err)
 	}
 
-	fmt.Println("Populating Persons collection...")
-	result := PopulatePersonsCollection(mongoClient, "dodle", persons)
-	if result != "" {
-		return fmt.Errorf("failed to populate persons collection: %s", result)
+	if err := s.CreateGuessesIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create guesses indexes: %w", err)
 	}
 
-	return nil
-}
-
-func UpdatePersonOfTheDay(mongoClient *mongo.Client) error {
-	previousPersons, err := GetPersonsOfTheDay(mongoClient, "dodle")
-	if err != nil {
-		return fmt.Errorf("failed to get previous persons of the day: %v", err)
+	if err := s.CreateScoresIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create scores indexes: %w", err)
 	}
 
-	personsAvailable, err := GetPersons(mongoClient, "dodle")
-	if err != nil {
-		return fmt.Errorf("failed to get persons: %v", err)
+	if err := s.CreateAPIKeysIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create api keys indexes: %w", err)
 	}
 
-	if len(personsAvailable.Persons) == 0 {
-		return fmt.Errorf("no persons available to update person of the day")
+	if err := s.CreateRateLimitsIndexes(ctx); err != nil {
+		return fmt.Errorf("failed to create rate limits indexes: %w", err)
 	}
 
-	isSelectable := false
-	candidate := personsAvailable.Persons[rand.Intn(len(personsAvailable.Persons))]
-	fmt.Println("New candidate for person of the day:", candidate.Firstname, candidate.Lastname)
-	for i := 0; i < len(personsAvailable.Persons); i++ {
-		candidate = personsAvailable.Persons[rand.Intn(len(personsAvailable.Persons))]
-		isSelectable = true
-		for _, person := range previousPersons {
-			if person.Firstname == candidate.Firstname && person.Lastname == candidate.Lastname {
-				isSelectable = false
-			}
-		}
-
-		if isSelectable {
-			break
-		}
-	}
-
-	dateOfToday := time.Now().Format("2006-01-02")
-
-	// If we already selected a candidate today we delete the previous one
-	if GetPersonOfTheDay, err := GetPersonOfTheDay(mongoClient, "dodle"); err == nil {
-		if GetPersonOfTheDay.Firstname != "" {
-			if err := DeletePersonOfTheDay(mongoClient, "dodle", dateOfToday); err != nil {
-				return fmt.Errorf("failed to delete previous person of the day: %v", err)
-			}
-			fmt.Println("Previous person of the day deleted successfully")
-		}
+	// Load persons from file
+	persons, err := data.OpenPersonsFile(personsFile)
+	if err != nil {
+		return fmt.Errorf("failed to open persons file: %w", err)
 	}
 
-	if err := CreatePersonOfTheDay(mongoClient, "dodle", candidate); err != nil {
-		return fmt.Errorf("failed to create person of the day: %v", err)
+	slog.DebugContext(ctx, "Syncing Persons collection")
+	report, err := s.SyncPersons(ctx, persons, dryRun)
+	if err != nil {
+		return fmt.Errorf("failed to sync persons collection: %w", err)
 	}
+	slog.InfoContext(ctx, "Persons synced", "dry_run", report.DryRun, "added", report.Added,
+		"changed", report.Changed, "removed", report.Removed, "unchanged", report.Unchanged)
 
-	dateToDelete := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
-
-	if err := DeletePersonOfTheDay(mongoClient, "dodle", dateToDelete); err != nil {
-		return fmt.Errorf("failed to delete previous person of the day: %v", err)
-	}
-	fmt.Println("Previous person of the day deleted successfully")
-	// You can add your logic here to update the person of the day in the database.
 	return nil
 }
 
-func GetPersonOfYesterday(client *mongo.Client, dbName string) (persons.Person, error) {
-	// Retrieve the person of yesterday from the GuessesOfTheMonth collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
-	if collection == nil {
-		return persons.Person{}, fmt.Errorf("GuessesOfTheMonth collection not found")
-	}
-
-	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02") // Format the date as YYYY-MM-DD
-
-	var doc struct {
-		Date   string         `bson:"date"`
-		Person persons.Person `bson:"person"`
-	}
-
-	err := collection.FindOne(context.TODO(), map[string]interface{}{"date": yesterday}).Decode(&doc)
-	if err != nil {
-		return persons.Person{}, fmt.Errorf("failed to find person of yesterday: %v", err)
-	}
-
-	return doc.Person, nil
-}
+// GetGuessID returns the id of the game of the given day, clients use it to
+// notice a new game
+func (s *MongoStore) GetGuessID(ctx context.Context, date string) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-func GetGuessID(client *mongo.Client, dbName string) (string, error) {
 	// Retrieve the ID of the guess from the GuessesOfTheMonth collection
-	collection := client.Database(dbName).Collection("GuessesOfTheMonth")
+	collection := s.database().Collection("GuessesOfTheMonth")
 	if collection == nil {
 		return "", fmt.Errorf("GuessesOfTheMonth collection not found")
 	}
 
-	cursor, err := collection.Find(context.TODO(), map[string]interface{}{})
-	if err != nil {
-		return "", fmt.Errorf("failed to find persons of the day: %v", err)
-	}
-	defer func() {
-		if err := cursor.Close(context.TODO()); err != nil {
-			log.Printf("Failed to close cursor: %v", err)
-		}
-	}()
-
 	var doc struct {
-		ID     string         `bson:"_id"`
-		Date   string         `bson:"date"`
-		Person persons.Person `bson:"person"`
+		ID string `bson:"_id"`
 	}
-
-	var guesses []string
-	for cursor.Next(context.TODO()) {
-
-		if err := cursor.Decode(&doc); err != nil {
-			return "", fmt.Errorf("failed to decode document: %v", err)
-		}
-
-		guesses = append(guesses, doc.ID)
+	err := collection.FindOne(ctx, map[string]interface{}{"date": date}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", fmt.Errorf("%w for %s", ErrNoPersonOfTheDay, date)
 	}
-
-	if len(guesses) == 0 {
-		return "", fmt.Errorf("no guesses found for today")
+	if err != nil {
+		return "", fmt.Errorf("failed to find person of the day: %w", err)
 	}
 
-	return guesses[len(guesses)-1], nil
+	return doc.ID, nil
 }
@@ -0,0 +1,105 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (s *MongoStore) CreateGuessesIndexes(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("GuessesCache")
+	if _, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: map[string]interface{}{"date": 1, "user_id": 1}},
+		{Keys: map[string]interface{}{"date": 1, "session_id": 1}},
+	}); err != nil {
+		return fmt.Errorf("failed to create guesses indexes: %w", err)
+	}
+	return nil
+}
+
+// playerFilter matches the guesses of a registered user, or of an anonymous
+// session when the player is not logged in
+func playerFilter(player persons.Player) map[string]interface{} {
+	if player.UserID != nil {
+		return map[string]interface{}{"user_id": *player.UserID}
+	}
+	return map[string]interface{}{"session_id": player.SessionID}
+}
+
+func (s *MongoStore) SaveGuess(ctx context.Context, player persons.Player, result persons.GuessResult) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("GuessesCache")
+
+	guess := persons.Guess{
+		UserID:    player.UserID,
+		SessionID: player.SessionID,
+		Date:      s.clock.Today(),
+		PersonID:  result.Guessed.ID,
+		Guessed:   result.Guessed,
+		Result:    result.Person,
+		Hints:     result.Hints,
+		Correct:   result.Correct,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if _, err := collection.InsertOne(ctx, guess); err != nil {
+		return fmt.Errorf("failed to save guess: %w", err)
+	}
+
+	return nil
+}
+
+// CountFailedGuesses counts today's wrong guesses of the player
+func (s *MongoStore) CountFailedGuesses(ctx context.Context, player persons.Player) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("GuessesCache")
+
+	filter := playerFilter(player)
+	filter["date"] = s.clock.Today()
+	filter["correct"] = false
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count guesses: %w", err)
+	}
+	return count, nil
+}
+
+func (s *MongoStore) GetGuessHistory(ctx context.Context, player persons.Player) ([]persons.Guess, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("GuessesCache")
+
+	filter := playerFilter(player)
+	filter["date"] = s.clock.Today()
+
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(map[string]interface{}{"created_at": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find guesses: %w", err)
+	}
+	defer func() {
+		if err := cursor.Close(ctx); err != nil {
+			slog.WarnContext(ctx, "Failed to close cursor", "error", err)
+		}
+	}()
+
+	guesses := []persons.Guess{}
+	if err := cursor.All(ctx, &guesses); err != nil {
+		return nil, fmt.Errorf("failed to decode guesses: %w", err)
+	}
+
+	return guesses, nil
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	persons "api/struct"
+	gameclock "api/utils/gameclock"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidPeriod = errors.New("period must be one of day, week or month")
+
+func (s *MongoStore) CreateScoresIndexes(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Scores")
+	if _, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// A player scores at most once per day
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "date", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: map[string]interface{}{"date": 1}},
+	}); err != nil {
+		return fmt.Errorf("failed to create scores indexes: %w", err)
+	}
+	return nil
+}
+
+// NewScore builds the score of a player who just solved the person of the
+// day from the guesses of that day, in order. Only the attempts up to the
+// first correct guess count.
+func NewScore(userID primitive.ObjectID, date string, guesses []persons.Guess) (persons.Score, error) {
+	if len(guesses) == 0 {
+		return persons.Score{}, fmt.Errorf("no guesses recorded for today")
+	}
+
+	attempts := len(guesses)
+	for i, guess := range guesses {
+		if guess.Correct {
+			attempts = i + 1
+			break
+		}
+	}
+	solvedAt := guesses[attempts-1].CreatedAt
+
+	return persons.Score{
+		UserID:      userID,
+		Date:        date,
+		PersonID:    guesses[attempts-1].PersonID,
+		Attempts:    attempts,
+		SolveTimeMs: solvedAt.Sub(guesses[0].CreatedAt).Milliseconds(),
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// RecordScore stores the score of a player who just solved today's person.
+// Solving again the same day keeps the first score.
+func (s *MongoStore) RecordScore(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	date := s.clock.Today()
+	guesses, err := s.GetGuessHistory(ctx, persons.Player{UserID: &userID})
+	if err != nil {
+		return fmt.Errorf("failed to get guesses: %w", err)
+	}
+	score, err := NewScore(userID, date, guesses)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.database().Collection("Scores").UpdateOne(
+		ctx,
+		map[string]interface{}{"user_id": userID, "date": date},
+		map[string]interface{}{"$setOnInsert": score},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record score: %w", err)
+	}
+
+	return nil
+}
+
+// PeriodStart returns the first game day of the leaderboard period
+// containing the game day now. Weeks start on Monday.
+func PeriodStart(period string, now time.Time) (string, error) {
+	switch period {
+	case "day":
+		return now.Format(gameclock.DateLayout), nil
+	case "week":
+		offset := (int(now.Weekday()) + 6) % 7
+		return now.AddDate(0, 0, -offset).Format(gameclock.DateLayout), nil
+	case "month":
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).Format(gameclock.DateLayout), nil
+	}
+	return "", ErrInvalidPeriod
+}
+
+// GetLeaderboard ranks players by days solved then total attempts. Players
+// with the same solved count and attempts share a rank; solve time only
+// orders them within the tie. $setWindowFields needs MongoDB 5.0 or newer.
+func (s *MongoStore) GetLeaderboard(ctx context.Context, period string, page int, pageSize int) (persons.Leaderboard, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	from, err := PeriodStart(period, s.clock.Date(0))
+	if err != nil {
+		return persons.Leaderboard{}, err
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "date", Value: bson.D{{Key: "$gte", Value: from}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$user_id"},
+			{Key: "solved", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "attempts", Value: bson.D{{Key: "$sum", Value: "$attempts"}}},
+			{Key: "solve_time_ms", Value: bson.D{{Key: "$sum", Value: "$solve_time_ms"}}},
+		}}},
+		{{Key: "$setWindowFields", Value: bson.D{
+			{Key: "sortBy", Value: bson.D{{Key: "solved", Value: -1}, {Key: "attempts", Value: 1}}},
+			{Key: "output", Value: bson.D{{Key: "rank", Value: bson.D{{Key: "$rank", Value: bson.D{}}}}}},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "rank", Value: 1}, {Key: "solve_time_ms", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$facet", Value: bson.D{
+			{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+			{Key: "entries", Value: bson.A{
+				bson.D{{Key: "$skip", Value: (page - 1) * pageSize}},
+				bson.D{{Key: "$limit", Value: pageSize}},
+				bson.D{{Key: "$lookup", Value: bson.D{
+					{Key: "from", Value: "Users"},
+					{Key: "localField", Value: "_id"},
+					{Key: "foreignField", Value: "_id"},
+					{Key: "as", Value: "user"},
+				}}},
+				bson.D{{Key: "$project", Value: bson.D{
+					{Key: "_id", Value: 0},
+					{Key: "rank", Value: 1},
+					{Key: "user_id", Value: "$_id"},
+					{Key: "username", Value: bson.D{{Key: "$first", Value: "$user.username"}}},
+					{Key: "solved", Value: 1},
+					{Key: "attempts", Value: 1},
+					{Key: "solve_time_ms", Value: 1},
+				}}},
+			}},
+		}}},
+	}
+
+	cursor, err := s.database().Collection("Scores").Aggregate(ctx, pipeline)
+	if err != nil {
+		return persons.Leaderboard{}, fmt.Errorf("failed to aggregate leaderboard: %w", err)
+	}
+
+	var results []struct {
+		Total []struct {
+			Count int `bson:"count"`
+		} `bson:"total"`
+		Entries []persons.LeaderboardEntry `bson:"entries"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return persons.Leaderboard{}, fmt.Errorf("failed to decode leaderboard: %w", err)
+	}
+
+	leaderboard := persons.Leaderboard{
+		Period:   period,
+		From:     from,
+		Page:     page,
+		PageSize: pageSize,
+		Entries:  []persons.LeaderboardEntry{},
+	}
+	if len(results) > 0 {
+		if len(results[0].Total) > 0 {
+			leaderboard.Total = results[0].Total[0].Count
+		}
+		if results[0].Entries != nil {
+			leaderboard.Entries = results[0].Entries
+		}
+	}
+
+	return leaderboard, nil
+}
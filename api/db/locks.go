@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AcquireLock takes the named lock for owner until ttl elapses. It returns
+// false without error when another owner holds an unexpired lock, so several
+// API replicas can agree on which one runs a job.
+func (s *MongoStore) AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Locks")
+	now := time.Now().UTC()
+
+	// Matches only a free lock; when it is held the upsert collides with the
+	// existing _id and fails with a duplicate key error
+	_, err := collection.UpdateOne(
+		ctx,
+		map[string]interface{}{
+			"_id": name,
+			"$or": []interface{}{
+				map[string]interface{}{"expires_at": map[string]interface{}{"$lte": now}},
+				map[string]interface{}{"owner": owner},
+			},
+		},
+		map[string]interface{}{"$set": map[string]interface{}{
+			"owner":      owner,
+			"expires_at": now.Add(ttl),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lock %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
+func (s *MongoStore) ReleaseLock(ctx context.Context, name string, owner string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Locks")
+	if _, err := collection.DeleteOne(ctx, map[string]interface{}{"_id": name, "owner": owner}); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrPersonExists = errors.New("a person with this id already exists")
+
+// ListPersons returns the whole catalogue for admins, soft-deleted persons
+// included on demand
+func (s *MongoStore) ListPersons(ctx context.Context, includeDeleted bool) ([]persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	filter := map[string]interface{}{}
+	if !includeDeleted {
+		filter["deleted_at"] = map[string]interface{}{"$exists": false}
+	}
+
+	cursor, err := s.database().Collection("Persons").Find(ctx, filter, options.Find().SetSort(map[string]interface{}{"_id": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find persons: %w", err)
+	}
+
+	list := []persons.Person{}
+	if err := cursor.All(ctx, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode persons: %w", err)
+	}
+	for i := range list {
+		list[i].Migrate()
+	}
+
+	return list, nil
+}
+
+func (s *MongoStore) CreatePerson(ctx context.Context, person persons.Person) (persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	person.SchemaVersion = 0
+	person.Migrate()
+	person.Source = persons.SourceAdmin
+	person.SeedHash = ""
+	person.DeletedAt = nil
+	person.DeletedBy = ""
+	person.Image = "" // set by uploading a portrait
+
+	if _, err := s.database().Collection("Persons").InsertOne(ctx, person); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return persons.Person{}, ErrPersonExists
+		}
+		return persons.Person{}, fmt.Errorf("failed to create person: %w", err)
+	}
+
+	return person, nil
+}
+
+// UpdatePerson replaces the editable fields of a person. The source and seed
+// hash are kept so a later sync only overrides the edit if persons.json
+// itself changes for that person. The image is only managed through the
+// portrait routes, so it is kept too.
+func (s *MongoStore) UpdatePerson(ctx context.Context, person persons.Person) (persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	current, err := s.FindPerson(ctx, person.ID)
+	if err != nil {
+		return persons.Person{}, err
+	}
+
+	person.SchemaVersion = 0
+	person.Migrate()
+	person.Source = current.Source
+	person.SeedHash = current.SeedHash
+	person.DeletedAt = current.DeletedAt
+	person.DeletedBy = current.DeletedBy
+	person.Image = current.Image
+
+	result, err := s.database().Collection("Persons").ReplaceOne(ctx, map[string]interface{}{"_id": person.ID}, person)
+	if err != nil {
+		return persons.Person{}, fmt.Errorf("failed to update person: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return persons.Person{}, ErrUnknownPerson
+	}
+
+	return person, nil
+}
+
+// SoftDeletePerson hides a person from the game while keeping it for the
+// past days and guesses that reference it. The deletion is marked as an
+// admin's so the persons.json sync never undoes it.
+func (s *MongoStore) SoftDeletePerson(ctx context.Context, id string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.setDeletedAt(ctx, id, map[string]interface{}{"$set": map[string]interface{}{"deleted_at": time.Now().UTC(), "deleted_by": persons.SourceAdmin}})
+}
+
+func (s *MongoStore) RestorePerson(ctx context.Context, id string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	return s.setDeletedAt(ctx, id, map[string]interface{}{"$unset": map[string]interface{}{"deleted_at": "", "deleted_by": ""}})
+}
+
+func (s *MongoStore) setDeletedAt(ctx context.Context, id string, update map[string]interface{}) error {
+	result, err := s.database().Collection("Persons").UpdateOne(ctx, map[string]interface{}{"_id": id}, update)
+	if err != nil {
+		return fmt.Errorf("failed to update person: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrUnknownPerson
+	}
+	return nil
+}
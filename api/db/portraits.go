@@ -0,0 +1,222 @@
+package db
+
+import (
+	persons "api/struct"
+	images "api/utils/images"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrNoPortrait = errors.New("no portrait for this person")
+
+// portraitsBucket opens the GridFS bucket of portraits. Streams of the bucket
+// take no context, they get the deadline of ctx instead.
+func (s *MongoStore) portraitsBucket(ctx context.Context) (*gridfs.Bucket, error) {
+	bucket, err := gridfs.NewBucket(s.database(), options.GridFSBucket().SetName("Portraits"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open portraits bucket: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := bucket.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if err := bucket.SetWriteDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	return bucket, nil
+}
+
+func portraitFilename(personID string, size string) string {
+	return personID + "/" + size + ".jpg"
+}
+
+// deletePortraitFiles removes every revision of a thumbnail except keep
+func deletePortraitFiles(ctx context.Context, bucket *gridfs.Bucket, filename string, keep interface{}) error {
+	cursor, err := bucket.FindContext(ctx, map[string]interface{}{"filename": filename})
+	if err != nil {
+		return fmt.Errorf("failed to find portrait files: %w", err)
+	}
+
+	var files []gridfs.File
+	if err := cursor.All(ctx, &files); err != nil {
+		return fmt.Errorf("failed to decode portrait files: %w", err)
+	}
+
+	for _, file := range files {
+		if file.ID == keep {
+			continue
+		}
+		if err := bucket.DeleteContext(ctx, file.ID); err != nil && !errors.Is(err, gridfs.ErrFileNotFound) {
+			return fmt.Errorf("failed to delete portrait file: %w", err)
+		}
+	}
+	return nil
+}
+
+// SavePortrait stores the thumbnails of a person's portrait in GridFS and
+// points Person.Image to the public portrait route. New files are written
+// before old ones are deleted so readers never miss a portrait.
+func (s *MongoStore) SavePortrait(ctx context.Context, personID string, thumbnails map[string][]byte) (persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.FindPerson(ctx, personID); err != nil {
+		return persons.Person{}, err
+	}
+
+	bucket, err := s.portraitsBucket(ctx)
+	if err != nil {
+		return persons.Person{}, err
+	}
+
+	for size, content := range thumbnails {
+		filename := portraitFilename(personID, size)
+		// The ETag is hashed once here, so revalidations never read the file
+		metadata := map[string]interface{}{"etag": persons.PortraitETag(content)}
+		id, err := bucket.UploadFromStream(filename, bytes.NewReader(content), options.GridFSUpload().SetMetadata(metadata))
+		if err != nil {
+			return persons.Person{}, fmt.Errorf("failed to upload portrait: %w", err)
+		}
+		if err := deletePortraitFiles(ctx, bucket, filename, id); err != nil {
+			return persons.Person{}, err
+		}
+	}
+
+	_, err = s.database().Collection("Persons").UpdateOne(ctx,
+		map[string]interface{}{"_id": personID},
+		map[string]interface{}{"$set": map[string]interface{}{"image": persons.PortraitPath(personID)}})
+	if err != nil {
+		return persons.Person{}, fmt.Errorf("failed to update person image: %w", err)
+	}
+
+	return s.FindPerson(ctx, personID)
+}
+
+// portraitFile is the GridFS files document of a thumbnail
+type portraitFile struct {
+	ID         interface{} `bson:"_id"`
+	UploadDate time.Time   `bson:"uploadDate"`
+	Metadata   struct {
+		ETag string `bson:"etag"`
+	} `bson:"metadata"`
+}
+
+// latestPortraitFile finds the most recent upload of a thumbnail
+func latestPortraitFile(ctx context.Context, bucket *gridfs.Bucket, personID string, size string) (portraitFile, error) {
+	cursor, err := bucket.FindContext(ctx,
+		map[string]interface{}{"filename": portraitFilename(personID, size)},
+		options.GridFSFind().SetSort(bson.D{{Key: "uploadDate", Value: -1}}).SetLimit(1))
+	if err != nil {
+		return portraitFile{}, fmt.Errorf("failed to find portrait: %w", err)
+	}
+
+	var files []portraitFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return portraitFile{}, fmt.Errorf("failed to decode portrait file: %w", err)
+	}
+	if len(files) == 0 {
+		return portraitFile{}, ErrNoPortrait
+	}
+	return files[0], nil
+}
+
+// FindPortrait returns the ETag and upload date of a thumbnail, without
+// reading its content. The ETag is empty for portraits uploaded before it
+// was stored.
+func (s *MongoStore) FindPortrait(ctx context.Context, personID string, size string) (persons.Portrait, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := s.portraitsBucket(ctx)
+	if err != nil {
+		return persons.Portrait{}, err
+	}
+
+	file, err := latestPortraitFile(ctx, bucket, personID, size)
+	if err != nil {
+		return persons.Portrait{}, err
+	}
+	return persons.Portrait{ETag: file.Metadata.ETag, UpdatedAt: file.UploadDate}, nil
+}
+
+func (s *MongoStore) GetPortrait(ctx context.Context, personID string, size string) (persons.Portrait, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	bucket, err := s.portraitsBucket(ctx)
+	if err != nil {
+		return persons.Portrait{}, err
+	}
+
+	file, err := latestPortraitFile(ctx, bucket, personID, size)
+	if err != nil {
+		return persons.Portrait{}, err
+	}
+
+	var buffer bytes.Buffer
+	stream, err := bucket.OpenDownloadStream(file.ID)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return persons.Portrait{}, ErrNoPortrait
+	}
+	if err != nil {
+		return persons.Portrait{}, fmt.Errorf("failed to open portrait: %w", err)
+	}
+	defer func() {
+		if err := stream.Close(); err != nil {
+			slog.WarnContext(ctx, "Failed to close portrait stream", "error", err)
+		}
+	}()
+
+	if _, err := buffer.ReadFrom(stream); err != nil {
+		return persons.Portrait{}, fmt.Errorf("failed to read portrait: %w", err)
+	}
+
+	// Portraits uploaded before the ETag was stored get it hashed here
+	etag := file.Metadata.ETag
+	if etag == "" {
+		etag = persons.PortraitETag(buffer.Bytes())
+	}
+
+	return persons.Portrait{
+		Content:   buffer.Bytes(),
+		ETag:      etag,
+		UpdatedAt: file.UploadDate,
+	}, nil
+}
+
+func (s *MongoStore) DeletePortrait(ctx context.Context, personID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.FindPerson(ctx, personID); err != nil {
+		return err
+	}
+
+	bucket, err := s.portraitsBucket(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.database().Collection("Persons").UpdateOne(ctx,
+		map[string]interface{}{"_id": personID},
+		map[string]interface{}{"$set": map[string]interface{}{"image": ""}})
+	if err != nil {
+		return fmt.Errorf("failed to update person image: %w", err)
+	}
+
+	for size := range images.Sizes {
+		if err := deletePortraitFiles(ctx, bucket, portraitFilename(personID, size), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	ratelimit "api/utils/ratelimit"
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var _ ratelimit.Buckets = (*MongoStore)(nil)
+
+func (s *MongoStore) CreateRateLimitsIndexes(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("RateLimits")
+	if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		// Let MongoDB drop buckets once they are full again
+		Keys:    map[string]interface{}{"expires_at": 1},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}); err != nil {
+		return fmt.Errorf("failed to create rate limits index: %w", err)
+	}
+
+	return nil
+}
+
+// TakeToken takes a token from the bucket of key shared by every replica.
+// The bucket is refilled and debited in a single update, so concurrent
+// requests cannot spend the same token.
+func (s *MongoStore) TakeToken(ctx context.Context, key string, burst int, interval time.Duration) (time.Duration, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("RateLimits")
+	now := time.Now().UTC()
+
+	// A missing bucket is a full one
+	refilled := map[string]interface{}{"$min": []interface{}{
+		burst,
+		map[string]interface{}{"$add": []interface{}{
+			map[string]interface{}{"$ifNull": []interface{}{"$tokens", burst}},
+			map[string]interface{}{"$divide": []interface{}{
+				map[string]interface{}{"$subtract": []interface{}{now, map[string]interface{}{"$ifNull": []interface{}{"$updated_at", now}}}},
+				interval.Milliseconds(),
+			}},
+		}},
+	}}
+	hasToken := map[string]interface{}{"$gte": []interface{}{"$tokens", 1}}
+	pipeline := []interface{}{
+		map[string]interface{}{"$set": map[string]interface{}{"tokens": refilled, "updated_at": now}},
+		map[string]interface{}{"$set": map[string]interface{}{
+			"allowed": hasToken,
+			"tokens":  map[string]interface{}{"$cond": []interface{}{hasToken, map[string]interface{}{"$subtract": []interface{}{"$tokens", 1}}, "$tokens"}},
+			// Once full again the bucket is the same as a missing one
+			"expires_at": now.Add(time.Duration(burst) * interval),
+		}},
+	}
+
+	var bucket struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, map[string]interface{}{"_id": key}, pipeline, opts).Decode(&bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		// Another request created the bucket first, it now exists
+		err = collection.FindOneAndUpdate(ctx, map[string]interface{}{"_id": key}, pipeline, opts).Decode(&bucket)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	if bucket.Allowed {
+		return 0, nil
+	}
+	return ratelimit.Wait(bucket.Tokens, interval), nil
+}
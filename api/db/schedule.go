@@ -0,0 +1,47 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// scheduleCycleID is the _id of the only document of the Schedule collection
+const scheduleCycleID = "current"
+
+// GetScheduleCycle returns the progress of the current schedule cycle, an
+// empty cycle when none was saved yet
+func (s *MongoStore) GetScheduleCycle(ctx context.Context) (persons.ScheduleCycle, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var cycle persons.ScheduleCycle
+	err := s.database().Collection("Schedule").FindOne(ctx, map[string]interface{}{"_id": scheduleCycleID}).Decode(&cycle)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return persons.ScheduleCycle{}, nil
+	}
+	if err != nil {
+		return persons.ScheduleCycle{}, fmt.Errorf("failed to get schedule cycle: %w", err)
+	}
+	return cycle, nil
+}
+
+func (s *MongoStore) SaveScheduleCycle(ctx context.Context, cycle persons.ScheduleCycle) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.database().Collection("Schedule").ReplaceOne(
+		ctx,
+		map[string]interface{}{"_id": scheduleCycleID},
+		cycle,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save schedule cycle: %w", err)
+	}
+	return nil
+}
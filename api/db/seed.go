@@ -0,0 +1,177 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// seedHash fingerprints a persons.json entry, ignoring catalogue bookkeeping
+func seedHash(person persons.Person) (string, error) {
+	person.Source = ""
+	person.SeedHash = ""
+	person.DeletedAt = nil
+	person.DeletedBy = ""
+
+	content, err := json.Marshal(person)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// seedOptionalFields are the persons.json fields left out of a document when
+// empty; they are unset when an entry drops them
+var seedOptionalFields = []string{"track", "promotion_start", "promotion_end"}
+
+// seedUpdate sets the fields of a person that come from persons.json,
+// keeping the portrait uploaded by an admin. The soft deletion is lifted
+// only when restore is true.
+func seedUpdate(person persons.Person, current persons.Person, found bool, restore bool) (map[string]interface{}, error) {
+	content, err := bson.Marshal(person)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	if err := bson.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "_id")
+	delete(fields, "deleted_at")
+	delete(fields, "deleted_by")
+	if found && current.Image == persons.PortraitPath(person.ID) {
+		delete(fields, "image")
+	}
+
+	unset := map[string]interface{}{}
+	for _, field := range seedOptionalFields {
+		if _, ok := fields[field]; !ok {
+			unset[field] = ""
+		}
+	}
+	if restore {
+		unset["deleted_at"] = ""
+		unset["deleted_by"] = ""
+	}
+
+	update := map[string]interface{}{"$set": fields}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	return update, nil
+}
+
+func (s *MongoStore) getAllPersons(ctx context.Context) (map[string]persons.Person, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	cursor, err := s.database().Collection("Persons").Find(ctx, map[string]interface{}{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find persons: %w", err)
+	}
+
+	var existing []persons.Person
+	if err := cursor.All(ctx, &existing); err != nil {
+		return nil, fmt.Errorf("failed to decode persons: %w", err)
+	}
+
+	byID := map[string]persons.Person{}
+	for _, person := range existing {
+		byID[person.ID] = person
+	}
+	return byID, nil
+}
+
+// SyncPersons brings the Persons collection in line with persons.json
+// without ever emptying it:
+//   - new entries are inserted, and the ones a previous sync soft-deleted
+//     are restored
+//   - entries whose persons.json content changed since the last sync get
+//     their persons.json fields updated; untouched entries keep any edit made
+//     through the admin API, and an uploaded portrait is always kept
+//   - persons that came from persons.json and left it are soft-deleted, so
+//     past days referencing them still resolve
+//
+// A person soft-deleted by an admin stays deleted, even when its entry
+// changes.
+//
+// Persons created through the admin API are never removed by a sync. With
+// dryRun the report is computed but nothing is written.
+func (s *MongoStore) SyncPersons(ctx context.Context, seed persons.Persons, dryRun bool) (persons.SeedReport, error) {
+	report := persons.SeedReport{DryRun: dryRun, Added: []string{}, Changed: []string{}, Removed: []string{}}
+
+	existing, err := s.getAllPersons(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	now := time.Now().UTC()
+	var writes []mongo.WriteModel
+	inSeed := map[string]bool{}
+
+	for _, person := range seed.Persons {
+		inSeed[person.ID] = true
+
+		hash, err := seedHash(person)
+		if err != nil {
+			return report, fmt.Errorf("failed to hash person %s: %w", person.ID, err)
+		}
+
+		current, found := existing[person.ID]
+		restore := found && current.DeletedAt != nil && current.DeletedBy == persons.SourceSeed
+		switch {
+		case !found || restore:
+			report.Added = append(report.Added, person.ID)
+		case current.SeedHash != hash:
+			report.Changed = append(report.Changed, person.ID)
+		default:
+			report.Unchanged++
+			continue
+		}
+
+		person.Source = persons.SourceSeed
+		person.SeedHash = hash
+		update, err := seedUpdate(person, current, found, restore)
+		if err != nil {
+			return report, fmt.Errorf("failed to encode person %s: %w", person.ID, err)
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(map[string]interface{}{"_id": person.ID}).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+
+	for id, person := range existing {
+		// Documents without a source predate the sync and came from the seed
+		if inSeed[id] || person.DeletedAt != nil || person.Source == persons.SourceAdmin {
+			continue
+		}
+
+		report.Removed = append(report.Removed, id)
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(map[string]interface{}{"_id": id}).
+			SetUpdate(map[string]interface{}{"$set": map[string]interface{}{"deleted_at": now, "deleted_by": persons.SourceSeed}}))
+	}
+
+	if dryRun || len(writes) == 0 {
+		return report, nil
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.database().Collection("Persons").BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return report, fmt.Errorf("failed to sync persons: %w", err)
+	}
+
+	return report, nil
+}
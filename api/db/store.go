@@ -0,0 +1,143 @@
+package db
+
+import (
+	persons "api/struct"
+	gameclock "api/utils/gameclock"
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+var (
+	ErrUnknownPerson = errors.New("unknown person")
+
+	// ErrNoPersonOfTheDay is returned when no person was picked for the day
+	ErrNoPersonOfTheDay = errors.New("no person of the day")
+)
+
+// PersonStore holds the catalogue of persons to guess and their portraits
+type PersonStore interface {
+	// GetPersons returns the guessable persons, leaving out soft-deleted ones
+	GetPersons(ctx context.Context) (persons.Persons, error)
+	FindPerson(ctx context.Context, id string) (persons.Person, error)
+	ListPersons(ctx context.Context, includeDeleted bool) ([]persons.Person, error)
+	CreatePerson(ctx context.Context, person persons.Person) (persons.Person, error)
+	UpdatePerson(ctx context.Context, person persons.Person) (persons.Person, error)
+	SoftDeletePerson(ctx context.Context, id string) error
+	RestorePerson(ctx context.Context, id string) error
+
+	SavePortrait(ctx context.Context, personID string, thumbnails map[string][]byte) (persons.Person, error)
+	// FindPortrait returns a portrait's ETag and date without its content
+	FindPortrait(ctx context.Context, personID string, size string) (persons.Portrait, error)
+	GetPortrait(ctx context.Context, personID string, size string) (persons.Portrait, error)
+	DeletePortrait(ctx context.Context, personID string) error
+}
+
+// PickStore holds the person of the day of each game day
+type PickStore interface {
+	GetPersonOfTheDay(ctx context.Context, date string) (persons.Person, error)
+	// GetPersonsOfTheDay returns the stored persons of the day, oldest first
+	GetPersonsOfTheDay(ctx context.Context) ([]persons.Person, error)
+	CreatePersonOfTheDay(ctx context.Context, date string, person persons.Person) error
+	DeletePersonOfTheDay(ctx context.Context, date string) error
+	GetGuessID(ctx context.Context, date string) (string, error)
+
+	// The schedule cycle remembers who was already picked, so changes to the
+	// persons never bring anyone back before everyone had their day
+	GetScheduleCycle(ctx context.Context) (persons.ScheduleCycle, error)
+	SaveScheduleCycle(ctx context.Context, cycle persons.ScheduleCycle) error
+
+	// Locks let several API replicas agree on which one rotates the person
+	AcquireLock(ctx context.Context, name string, owner string, ttl time.Duration) (bool, error)
+	ReleaseLock(ctx context.Context, name string, owner string) error
+}
+
+// GuessStore holds today's guesses of every player and the scores of
+// registered players
+type GuessStore interface {
+	SaveGuess(ctx context.Context, player persons.Player, result persons.GuessResult) error
+	CountFailedGuesses(ctx context.Context, player persons.Player) (int64, error)
+	GetGuessHistory(ctx context.Context, player persons.Player) ([]persons.Guess, error)
+
+	RecordScore(ctx context.Context, userID primitive.ObjectID) error
+	GetLeaderboard(ctx context.Context, period string, page int, pageSize int) (persons.Leaderboard, error)
+}
+
+// UserStore holds registered players and their sessions
+type UserStore interface {
+	RegisterUser(ctx context.Context, credentials persons.Credentials) (persons.User, error)
+	AuthenticateUser(ctx context.Context, credentials persons.Credentials) (persons.User, error)
+	CreateSession(ctx context.Context, userID primitive.ObjectID) (string, error)
+	GetUserBySession(ctx context.Context, token string) (persons.User, error)
+}
+
+// APIKeyStore holds the keys of private API callers
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, request persons.APIKeyRequest) (string, persons.APIKey, error)
+	FindAPIKey(ctx context.Context, prefix string) (persons.APIKey, error)
+	TouchAPIKey(ctx context.Context, id primitive.ObjectID) error
+	ListAPIKeys(ctx context.Context) ([]persons.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id primitive.ObjectID) error
+}
+
+// Store is everything the API reads and writes
+type Store interface {
+	PersonStore
+	PickStore
+	GuessStore
+	UserStore
+	APIKeyStore
+
+	// Ping checks that the store can be reached
+	Ping(ctx context.Context) error
+}
+
+// MongoStore is the Store backed by a MongoDB database
+type MongoStore struct {
+	client *mongo.Client
+	dbName string
+
+	// timeout bounds every store operation, so a slow database fails the
+	// request instead of hanging it
+	timeout time.Duration
+
+	// clock decides the game day guesses and scores are recorded for
+	clock *gameclock.Clock
+}
+
+var _ Store = (*MongoStore)(nil)
+
+func NewMongoStore(client *mongo.Client, dbName string, timeout time.Duration, clock *gameclock.Clock) *MongoStore {
+	return &MongoStore{client: client, dbName: dbName, timeout: timeout, clock: clock}
+}
+
+// withTimeout derives the context of one store operation from the caller's
+func (s *MongoStore) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// IsTimeout reports whether err comes from an operation that ran out of time
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err)
+}
+
+// pingTimeout bounds readiness checks, so a hung database fails the probe
+// instead of blocking it
+const pingTimeout = 2 * time.Second
+
+func (s *MongoStore) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return s.client.Ping(ctx, readpref.Primary())
+}
+
+func (s *MongoStore) database() *mongo.Database {
+	return s.client.Database(s.dbName)
+}
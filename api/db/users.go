@@ -0,0 +1,205 @@
+package db
+
+import (
+	persons "api/struct"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// SessionDuration is how long a session token stays valid after login
+const SessionDuration = 30 * 24 * time.Hour
+
+var (
+	ErrUsernameTaken      = errors.New("username already taken")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrInvalidSession     = errors.New("invalid or expired session")
+)
+
+func (s *MongoStore) CreateUsersIndexes(ctx context.Context) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	users := s.database().Collection("Users")
+	if _, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    map[string]interface{}{"username": 1},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		return fmt.Errorf("failed to create users index: %w", err)
+	}
+
+	sessions := s.database().Collection("Sessions")
+	if _, err := sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    map[string]interface{}{"token": 1},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			// Let MongoDB drop sessions once they expire
+			Keys:    map[string]interface{}{"expires_at": 1},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create sessions indexes: %w", err)
+	}
+
+	return nil
+}
+
+// NormalizeUsername makes usernames case insensitive
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// HashToken is how session tokens and API keys are stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewUser builds the user to store for the credentials, with a hashed password
+func NewUser(credentials persons.Credentials) (persons.User, error) {
+	// bcrypt generates and embeds a random salt in the hash
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return persons.User{}, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return persons.User{
+		Username:  NormalizeUsername(credentials.Username),
+		Password:  string(hash),
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// CheckPassword returns ErrInvalidCredentials unless password is the user's
+func CheckPassword(user persons.User, password string) error {
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// NewSession issues a new session token for the user. Only a hash of the
+// token is kept in the session so a database leak does not expose live
+// sessions.
+func NewSession(userID primitive.ObjectID) (string, persons.Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", persons.Session{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now().UTC()
+	return token, persons.Session{
+		Token:     HashToken(token),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionDuration),
+	}, nil
+}
+
+func (s *MongoStore) RegisterUser(ctx context.Context, credentials persons.Credentials) (persons.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Users")
+
+	user, err := NewUser(credentials)
+	if err != nil {
+		return persons.User{}, err
+	}
+
+	result, err := collection.InsertOne(ctx, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return persons.User{}, ErrUsernameTaken
+		}
+		return persons.User{}, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return user, nil
+}
+
+func (s *MongoStore) AuthenticateUser(ctx context.Context, credentials persons.Credentials) (persons.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Users")
+
+	var user persons.User
+	err := collection.FindOne(ctx, map[string]interface{}{"username": NormalizeUsername(credentials.Username)}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return persons.User{}, ErrInvalidCredentials
+		}
+		return persons.User{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	if err := CheckPassword(user, credentials.Password); err != nil {
+		return persons.User{}, err
+	}
+
+	return user, nil
+}
+
+func (s *MongoStore) CreateSession(ctx context.Context, userID primitive.ObjectID) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	collection := s.database().Collection("Sessions")
+
+	token, session, err := NewSession(userID)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := collection.InsertOne(ctx, session); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return token, nil
+}
+
+func (s *MongoStore) GetUserBySession(ctx context.Context, token string) (persons.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if token == "" {
+		return persons.User{}, ErrInvalidSession
+	}
+
+	var session persons.Session
+	err := s.database().Collection("Sessions").FindOne(ctx, map[string]interface{}{
+		"token":      HashToken(token),
+		"expires_at": map[string]interface{}{"$gt": time.Now().UTC()},
+	}).Decode(&session)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return persons.User{}, ErrInvalidSession
+		}
+		return persons.User{}, fmt.Errorf("failed to find session: %w", err)
+	}
+
+	var user persons.User
+	err = s.database().Collection("Users").FindOne(ctx, map[string]interface{}{"_id": session.UserID}).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return persons.User{}, ErrInvalidSession
+		}
+		return persons.User{}, fmt.Errorf("failed to find user: %w", err)
+	}
+
+	return user, nil
+}
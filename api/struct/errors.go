@@ -0,0 +1,32 @@
+package persons
+
+// Error codes of ErrorResponse, stable so clients can branch on them
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "payload_too_large"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeInternal         = "internal"
+	CodeUnavailable      = "unavailable"
+	CodeRateLimited      = "rate_limited"
+
+	CodeUnknownPerson      = "unknown_person"
+	CodeNoPersonOfTheDay   = "no_person_today"
+	CodeHintLocked         = "hint_locked"
+	CodeInvalidSession     = "invalid_session"
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeUsernameTaken      = "username_taken"
+	CodePersonExists       = "person_exists"
+)
+
+// ErrorResponse is the body of every error answered by the API
+type ErrorResponse struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
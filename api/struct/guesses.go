@@ -0,0 +1,55 @@
+package persons
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Player identifies who submitted a guess: a registered user when UserID is
+// set, otherwise an anonymous browser session
+type Player struct {
+	UserID    *primitive.ObjectID
+	SessionID string
+}
+
+type Guess struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID    *primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	SessionID string              `bson:"session_id,omitempty" json:"-"`
+	Date      string              `bson:"date" json:"date"`
+	PersonID  string              `bson:"person_id" json:"person_id"`
+	Guessed   Person              `bson:"guessed" json:"guessed"`
+	Result    Person              `bson:"result" json:"result"`
+	Hints     Hints               `bson:"hints" json:"hints"`
+	Correct   bool                `bson:"correct" json:"correct"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// Score is recorded the first time a registered player solves a day
+type Score struct {
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Date        string             `bson:"date" json:"date"`
+	PersonID    string             `bson:"person_id" json:"person_id"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	SolveTimeMs int64              `bson:"solve_time_ms" json:"solve_time_ms"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type LeaderboardEntry struct {
+	Rank        int                `bson:"rank" json:"rank"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Username    string             `bson:"username" json:"username"`
+	Solved      int                `bson:"solved" json:"solved"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	SolveTimeMs int64              `bson:"solve_time_ms" json:"solve_time_ms"`
+}
+
+type Leaderboard struct {
+	Period   string             `json:"period"`
+	From     string             `json:"from"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+	Total    int                `json:"total"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
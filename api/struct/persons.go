@@ -1,15 +1,233 @@
 package persons
 
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// SchemaVersion is the current version of the Person document. Version 1
+// documents only have the free-form string fields, version 2 documents have
+// no stable id.
+const SchemaVersion = 3
+
 type Person struct {
+	// ID is a stable slug such as "pierre-louis-leclerc", set in persons.json
+	ID            string `json:"id" bson:"_id"`
+	SchemaVersion int    `json:"schema_version" bson:"schema_version"`
+	Firstname     string `json:"firstname"`
+	Lastname      string `json:"lastname"`
+	Gender        string `json:"gender"`
+	Type          string `json:"type"`
+	Workplace     string `json:"workplace"`
+	Image         string `json:"image"`
+	Hint          string `json:"hint"`
+
+	// Structured attributes, zero when unknown
+	Track          string `json:"track,omitempty" bson:"track,omitempty"`
+	PromotionStart int    `json:"promotion_start,omitempty" bson:"promotion_start,omitempty"`
+	PromotionEnd   int    `json:"promotion_end,omitempty" bson:"promotion_end,omitempty"`
+
+	// Catalogue bookkeeping: where the person comes from, the hash of its
+	// persons.json entry at the last sync, and when and by whom (an admin or
+	// the sync) it was soft-deleted
+	Source    string     `json:"source,omitempty" bson:"source,omitempty"`
+	SeedHash  string     `json:"-" bson:"seed_hash,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	DeletedBy string     `json:"deleted_by,omitempty" bson:"deleted_by,omitempty"`
+}
+
+var (
+	slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	genders     = map[string]bool{"Homme": true, "Femme": true, "Autre": true}
+)
+
+// Validate checks every editable field of a person, returning all the
+// problems found at once
+func (p Person) Validate() error {
+	var problems []string
+	check := func(ok bool, problem string) {
+		if !ok {
+			problems = append(problems, problem)
+		}
+	}
+	length := func(value string) int {
+		return len([]rune(strings.TrimSpace(value)))
+	}
+	optionalYear := func(year int, min int) bool {
+		return year == 0 || (year >= min && year <= 2100)
+	}
+
+	check(slugPattern.MatchString(p.ID) && len(p.ID) <= 128, "id must be a lower case slug such as \"firstname-lastname\"")
+	check(length(p.Firstname) > 0 && length(p.Firstname) <= 64, "firstname is required and at most 64 characters")
+	check(length(p.Lastname) > 0 && length(p.Lastname) <= 64, "lastname is required and at most 64 characters")
+	check(genders[p.Gender], "gender must be one of Homme, Femme or Autre")
+	check(length(p.Type) > 0 && length(p.Type) <= 32, "type is required and at most 32 characters")
+	check(length(p.Workplace) > 0 && length(p.Workplace) <= 128, "workplace is required and at most 128 characters")
+	check(len(p.Image) <= 512, "image is at most 512 characters")
+	check(length(p.Hint) > 0 && length(p.Hint) <= 256, "hint is required and at most 256 characters")
+	check(length(p.Track) <= 16, "track is at most 16 characters")
+	check(optionalYear(p.PromotionStart, 2000), "promotion_start must be a year between 2000 and 2100")
+	check(optionalYear(p.PromotionEnd, 2000), "promotion_end must be a year between 2000 and 2100")
+	check(p.PromotionStart == 0 || p.PromotionEnd == 0 || p.PromotionEnd > p.PromotionStart, "promotion_end must be after promotion_start")
+
+	if match := promotionPattern.FindStringSubmatch(p.Type); match != nil {
+		start, _ := strconv.Atoi(match[2])
+		check(p.PromotionStart == 0 || p.PromotionStart == 2000+start, "promotion_start does not match type "+p.Type)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid person: " + strings.Join(e.Problems, "; ")
+}
+
+// Person sources
+const (
+	SourceSeed  = "seed"
+	SourceAdmin = "admin"
+)
+
+// SeedReport lists what a sync from persons.json changed, by person id
+type SeedReport struct {
+	DryRun    bool     `json:"dry_run"`
+	Added     []string `json:"added"`
+	Changed   []string `json:"changed"`
+	Removed   []string `json:"removed"`
+	Unchanged int      `json:"unchanged"`
+}
+
+// Promotion types look like "DO24-27": track, start year, end year
+var promotionPattern = regexp.MustCompile(`^([A-Za-z]+)(\d{2})-(\d{2})$`)
+
+var nonSlugCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug builds a person id from their name: accents removed, lower case,
+// words joined with dashes
+func Slug(firstname string, lastname string) string {
+	stripAccents := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	name, _, err := transform.String(stripAccents, firstname+" "+lastname)
+	if err != nil {
+		name = firstname + " " + lastname
+	}
+	return strings.Trim(nonSlugCharacters.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// Migrate upgrades a person decoded from an older schema to the current one,
+// deriving the id from the name and the promotion attributes from Type when
+// they are missing
+func (p *Person) Migrate() {
+	if p.SchemaVersion >= SchemaVersion {
+		return
+	}
+
+	if p.ID == "" {
+		p.ID = Slug(p.Firstname, p.Lastname)
+	}
+
+	if match := promotionPattern.FindStringSubmatch(p.Type); match != nil {
+		start, _ := strconv.Atoi(match[2])
+		end, _ := strconv.Atoi(match[3])
+		if p.Track == "" {
+			p.Track = match[1]
+		}
+		if p.PromotionStart == 0 {
+			p.PromotionStart = 2000 + start
+		}
+		if p.PromotionEnd == 0 {
+			p.PromotionEnd = 2000 + end
+		}
+	}
+
+	p.SchemaVersion = SchemaVersion
+}
+
+type Persons struct {
+	Persons []Person `json:"persons"`
+}
+
+// PublicPerson is what anyone can see about a guessable person: enough to
+// pick them, nothing that helps find the answer
+type PublicPerson struct {
+	ID        string `json:"id"`
 	Firstname string `json:"firstname"`
 	Lastname  string `json:"lastname"`
-	Gender    string `json:"gender"`
-	Type      string `json:"type"`
-	Workplace string `json:"workplace"`
 	Image     string `json:"image"`
-	Hint      string `json:"hint"`
 }
 
-type Persons struct {
-	Persons []Person `json:"persons"`
+type PublicPersons struct {
+	Persons []PublicPerson `json:"persons"`
+}
+
+func (p Person) Public() PublicPerson {
+	return PublicPerson{
+		ID:        p.ID,
+		Firstname: p.Firstname,
+		Lastname:  p.Lastname,
+		Image:     p.Image,
+	}
+}
+
+// WithoutHint returns the person without its hint, to show a guessed
+// person's attributes without giving the hint away
+func (p Person) WithoutHint() Person {
+	p.Hint = ""
+	return p
+}
+
+// GuessRequest names the person a player guesses by id. Firstname and
+// Lastname are only read from older clients that do not send the id.
+type GuessRequest struct {
+	ID        string `json:"id"`
+	Firstname string `json:"firstname"`
+	Lastname  string `json:"lastname"`
+}
+
+// GuessResult is the outcome of a guess. Person only has the fields equal
+// to the answer, kept for older clients; Hints is the per-field verdict.
+type GuessResult struct {
+	Correct bool   `json:"correct"`
+	Guessed Person `json:"guessed"`
+	Person  Person `json:"person"`
+	Hints   Hints  `json:"hints"`
+}
+
+// Verdict tells how a guessed attribute compares to the person of the day.
+// For ordered attributes, Higher and Lower say where the answer lies relative
+// to the guess (Higher means the answer is greater than the guessed value).
+type Verdict string
+
+const (
+	VerdictCorrect Verdict = "correct"
+	VerdictPartial Verdict = "partial"
+	VerdictWrong   Verdict = "wrong"
+	VerdictHigher  Verdict = "higher"
+	VerdictLower   Verdict = "lower"
+)
+
+// Hints holds one verdict per attribute. Structured attributes are left
+// empty when either side does not know them.
+type Hints struct {
+	Firstname      Verdict `json:"firstname" bson:"firstname"`
+	Lastname       Verdict `json:"lastname" bson:"lastname"`
+	Gender         Verdict `json:"gender" bson:"gender"`
+	Type           Verdict `json:"type" bson:"type"`
+	Workplace      Verdict `json:"workplace" bson:"workplace"`
+	Track          Verdict `json:"track,omitempty" bson:"track,omitempty"`
+	PromotionStart Verdict `json:"promotion_start,omitempty" bson:"promotion_start,omitempty"`
+	PromotionEnd   Verdict `json:"promotion_end,omitempty" bson:"promotion_end,omitempty"`
 }
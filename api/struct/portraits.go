@@ -0,0 +1,27 @@
+package persons
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Portrait is one stored thumbnail of a person's picture
+type Portrait struct {
+	Content   []byte
+	ETag      string
+	UpdatedAt time.Time
+}
+
+// PortraitPath is the public URL path of a person's portrait, stored in
+// Person.Image once a portrait has been uploaded
+func PortraitPath(id string) string {
+	return "/public/v1/persons/" + id + "/image"
+}
+
+// PortraitETag is the entity tag of a thumbnail, derived from its content so
+// it changes with every new picture and stays the same across replicas
+func PortraitETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
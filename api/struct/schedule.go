@@ -0,0 +1,18 @@
+package persons
+
+// ScheduleCycle is the progress of the current person of the day cycle:
+// every person of the pool is picked once before anyone comes back
+type ScheduleCycle struct {
+	Number int         `bson:"number" json:"number"`
+	Picks  []CyclePick `bson:"picks" json:"picks"`
+
+	// Previous is the person who closed the previous cycle, never the first
+	// of this one
+	Previous string `bson:"previous,omitempty" json:"previous,omitempty"`
+}
+
+// CyclePick is a person picked for a game day of the cycle
+type CyclePick struct {
+	Date     string `bson:"date" json:"date"`
+	PersonID string `bson:"person_id" json:"person_id"`
+}
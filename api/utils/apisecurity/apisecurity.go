@@ -1,10 +1,80 @@
 package apisecurity
 
 import (
+	db "api/db"
+	persons "api/struct"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"time"
 )
 
-func IsAuthorized(r *http.Request) bool {
-	return r.Header.Get("API-Token") == os.Getenv("API_TOKEN")
+// APITokenHeader carries the key of private API callers
+const APITokenHeader = "API-Token"
+
+var (
+	ErrUnauthorized = errors.New("missing or invalid api key")
+	ErrForbidden    = errors.New("api key lacks the required scope")
+)
+
+// legacyTokenMatches compares the header against the configured API token
+// in constant time. The legacy token only manages keys, it is there to mint
+// the first named keys.
+func legacyTokenMatches(token string, legacy string) bool {
+	if legacy == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(legacy)) == 1
+}
+
+// Authorize checks that the request carries an active API key with the
+// given scope, or the legacy token for key management, and records when the
+// key was last used
+func Authorize(r *http.Request, keys db.APIKeyStore, legacyToken string, scope string) error {
+	token := r.Header.Get(APITokenHeader)
+	if legacyTokenMatches(token, legacyToken) {
+		if scope != persons.ScopeManageKeys {
+			return ErrForbidden
+		}
+		return nil
+	}
+
+	// Keys look like dodle_<prefix>_<secret>
+	parts := strings.Split(strings.TrimPrefix(token, db.APIKeyPrefix), "_")
+	if !strings.HasPrefix(token, db.APIKeyPrefix) || len(parts) != 2 {
+		return ErrUnauthorized
+	}
+
+	apiKey, err := keys.FindAPIKey(r.Context(), parts[0])
+	if errors.Is(err, db.ErrUnknownAPIKey) {
+		return ErrUnauthorized
+	}
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(db.HashToken(token)), []byte(apiKey.Hash)) != 1 || !apiKey.Active(time.Now()) {
+		return ErrUnauthorized
+	}
+	if !apiKey.HasScope(scope) {
+		return ErrForbidden
+	}
+
+	if err := keys.TouchAPIKey(r.Context(), apiKey.ID); err != nil {
+		slog.WarnContext(r.Context(), "Failed to record api key use", "error", err)
+	}
+
+	return nil
+}
+
+// SessionToken extracts the player session token from the
+// "Authorization: Bearer <token>" header, or returns an empty string
+func SessionToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[len("Bearer "):])
 }
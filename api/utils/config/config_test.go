@@ -0,0 +1,99 @@
+package config_test
+
+import (
+	config "api/utils/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Setenv("API_TOKEN", "")
+	t.Setenv("SCHEDULE_SEED", "seed")
+	file := writeFile(t, `{"api_token":"from-file","addr":":7000","database":"file-db","hint_after_guesses":5}`)
+
+	t.Setenv("MONGODB_DATABASE", "env-db")
+	t.Setenv("LISTEN_ADDR", ":7001")
+
+	cfg, err := config.Load([]string{"-config", file, "-addr", ":7002"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"default", cfg.PersonsFile, "./data/persons.json"},
+		{"file", cfg.APIToken, "from-file"},
+		{"file", cfg.HintAfterGuesses, int64(5)},
+		{"env over file", cfg.Database, "env-db"},
+		{"flag over env", cfg.Addr, ":7002"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadCORSOrigins(t *testing.T) {
+	t.Setenv("API_TOKEN", "token")
+	t.Setenv("SCHEDULE_SEED", "seed")
+	t.Setenv("CORS_ORIGINS", " https://dodle.example, http://localhost:3000 ,")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := strings.Join(cfg.CORSOrigins, " "); got != "https://dodle.example http://localhost:3000" {
+		t.Errorf("CORSOrigins = %q", got)
+	}
+}
+
+func TestLoadFailsFast(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"empty schedule seed", map[string]string{"API_TOKEN": "token", "SCHEDULE_SEED": ""}, nil, "SCHEDULE_SEED is required"},
+		{"unknown time zone", map[string]string{"API_TOKEN": "token", "GAME_TIMEZONE": "Mars/Olympus"}, nil, "invalid game time zone"},
+		{"rollover hour out of range", map[string]string{"API_TOKEN": "token"}, []string{"-rollover-hour", "24"}, "invalid rollover hour"},
+		{"malformed number", map[string]string{"API_TOKEN": "token", "HINT_AFTER_GUESSES": "three"}, nil, "invalid HINT_AFTER_GUESSES"},
+		{"secrets have no flag", map[string]string{"API_TOKEN": "token"}, []string{"-api-token", "token"}, "flag provided but not defined"},
+		{"unknown file option", map[string]string{"API_TOKEN": "token"}, []string{"-config", "FILE"}, "unknown field"},
+		{"origin with a path", map[string]string{"API_TOKEN": "token", "CORS_ORIGINS": "https://dodle.example/app"}, nil, "invalid CORS origin"},
+		{"credentials to any origin", map[string]string{"API_TOKEN": "token", "CORS_ORIGINS": "*", "CORS_CREDENTIALS": "true"}, nil, "cannot be allowed to any origin"},
+		{"unknown log level", map[string]string{"API_TOKEN": "token", "LOG_LEVEL": "verbose"}, nil, "invalid log level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			for i, arg := range tt.args {
+				if arg == "FILE" {
+					tt.args[i] = writeFile(t, `{"port":8080}`)
+				}
+			}
+
+			_, err := config.Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
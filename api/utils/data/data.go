@@ -5,18 +5,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 )
 
-func OpenPersonsFile() (persons.Persons, error) {
-	jsonFile, err := os.Open("./data/persons.json")
+func OpenPersonsFile(path string) (persons.Persons, error) {
+	jsonFile, err := os.Open(path)
 	if err != nil {
 		return persons.Persons{}, fmt.Errorf("error opening file: %v", err)
 	}
 	defer func() {
 		if err := jsonFile.Close(); err != nil {
-			log.Printf("Failed to close file: %v", err)
+			slog.Warn("Failed to close file", "error", err)
 		}
 	}()
 
@@ -33,5 +33,21 @@ func OpenPersonsFile() (persons.Persons, error) {
 		return persons.Persons{}, fmt.Errorf("error unmarshaling JSON: %v", err)
 	}
 
+	// Bring entries written for an older schema up to date
+	seen := map[string]bool{}
+	for i := range personsList {
+		personsList[i].Migrate()
+
+		if err := personsList[i].Validate(); err != nil {
+			return persons.Persons{}, fmt.Errorf("person %d (%s %s): %v", i, personsList[i].Firstname, personsList[i].Lastname, err)
+		}
+
+		id := personsList[i].ID
+		if seen[id] {
+			return persons.Persons{}, fmt.Errorf("duplicate person id %q", id)
+		}
+		seen[id] = true
+	}
+
 	return persons.Persons{Persons: personsList}, nil
 }
@@ -0,0 +1,232 @@
+package game
+
+import (
+	db "api/db"
+	persons "api/struct"
+	gameclock "api/utils/gameclock"
+	hints "api/utils/hints"
+	metrics "api/utils/metrics"
+	schedule "api/utils/schedule"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	rotationLock    = "person-of-the-day-rotation"
+	rotationLockTTL = time.Minute
+
+	// keepDays is how long a person of the day stays stored
+	keepDays = 10
+)
+
+var (
+	// lockOwner identifies this API instance when taking locks
+	lockOwner     = newLockOwner()
+	rotationMutex sync.Mutex
+)
+
+func newLockOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, time.Now().UnixNano())
+}
+
+// TryGuess compares the guessed person with the person of the day. The
+// guessed person's attributes are read from the store, never trusted from
+// the client. Besides the per-field verdicts, the result carries the legacy
+// masked person with only the exactly matching fields filled.
+func TryGuess(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string, guess persons.GuessRequest) (persons.GuessResult, error) {
+	// Ids are slugs of the name, so older clients sending names still work
+	id := guess.ID
+	if id == "" {
+		id = persons.Slug(guess.Firstname, guess.Lastname)
+	}
+
+	guessedPerson, err := store.FindPerson(ctx, id)
+	if err != nil {
+		return persons.GuessResult{}, err
+	}
+	if guessedPerson.DeletedAt != nil {
+		return persons.GuessResult{}, db.ErrUnknownPerson
+	}
+
+	// Check if the guess matches the person of the day
+	personOfTheDay, err := EnsurePersonOfTheDay(ctx, store, clock, seed)
+	if err != nil {
+		return persons.GuessResult{}, fmt.Errorf("failed to get person of the day: %w", err)
+	}
+
+	result := persons.GuessResult{
+		Guessed: guessedPerson.WithoutHint(),
+		Hints:   hints.Compare(personOfTheDay, guessedPerson),
+	}
+
+	// Compare the guess with the person of the day
+	if guessedPerson.ID == personOfTheDay.ID {
+		result.Correct = true
+		result.Person = personOfTheDay.WithoutHint()
+		return result, nil // Correct guess
+	}
+
+	// Image and hint are never echoed, they would give the answer away
+	if personOfTheDay.Firstname == guessedPerson.Firstname {
+		result.Person.Firstname = personOfTheDay.Firstname
+	}
+	if personOfTheDay.Lastname == guessedPerson.Lastname {
+		result.Person.Lastname = personOfTheDay.Lastname
+	}
+	if personOfTheDay.Gender == guessedPerson.Gender {
+		result.Person.Gender = personOfTheDay.Gender
+	}
+	if personOfTheDay.Workplace == guessedPerson.Workplace {
+		result.Person.Workplace = personOfTheDay.Workplace
+	}
+	if personOfTheDay.Type == guessedPerson.Type {
+		result.Person.Type = personOfTheDay.Type
+	}
+
+	return result, nil // Incorrect guess
+}
+
+// UpdatePersonOfTheDay stores today's pick from the schedule derived from
+// seed and the stored cycle, replacing any person already stored for today,
+// and forgets the person of keepDays ago. Anyone knowing the seed, the
+// persons list and the cycle can compute every upcoming person of the day.
+func UpdatePersonOfTheDay(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string) error {
+	if err := updatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+		metrics.Rotations.WithLabelValues(metrics.ResultFailure).Inc()
+		return err
+	}
+	metrics.Rotations.WithLabelValues(metrics.ResultSuccess).Inc()
+	return nil
+}
+
+func updatePersonOfTheDay(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string) error {
+	personsAvailable, err := store.GetPersons(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get persons: %w", err)
+	}
+
+	if len(personsAvailable.Persons) == 0 {
+		return fmt.Errorf("no persons available to update person of the day")
+	}
+
+	cycle, err := store.GetScheduleCycle(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get schedule cycle: %w", err)
+	}
+
+	dateOfToday := clock.Today()
+	candidate, cycle, err := schedule.Next(seed, personsAvailable.Persons, cycle, dateOfToday)
+	if err != nil {
+		return fmt.Errorf("failed to pick person of the day: %w", err)
+	}
+	slog.DebugContext(ctx, "New candidate for person of the day", "date", dateOfToday)
+
+	// If we already selected a candidate today we delete the previous one
+	if previous, err := store.GetPersonOfTheDay(ctx, dateOfToday); err == nil {
+		if previous.Firstname != "" {
+			if err := store.DeletePersonOfTheDay(ctx, dateOfToday); err != nil {
+				return fmt.Errorf("failed to delete previous person of the day: %w", err)
+			}
+			slog.DebugContext(ctx, "Replaced today's person of the day", "date", dateOfToday)
+		}
+	}
+
+	if err := store.CreatePersonOfTheDay(ctx, dateOfToday, candidate); err != nil {
+		return fmt.Errorf("failed to create person of the day: %w", err)
+	}
+
+	if err := store.SaveScheduleCycle(ctx, cycle); err != nil {
+		return fmt.Errorf("failed to save schedule cycle: %w", err)
+	}
+
+	if err := store.DeletePersonOfTheDay(ctx, clock.Day(-keepDays)); err != nil {
+		return fmt.Errorf("failed to delete previous person of the day: %w", err)
+	}
+	slog.DebugContext(ctx, "Forgot an old person of the day", "date", clock.Day(-keepDays))
+	return nil
+}
+
+// EnsurePersonOfTheDay returns today's person, creating it when the daily
+// rotation has not run yet. A lock makes sure only one replica creates it;
+// the others wait for it to appear.
+func EnsurePersonOfTheDay(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string) (persons.Person, error) {
+	person, err := store.GetPersonOfTheDay(ctx, clock.Today())
+	if !errors.Is(err, db.ErrNoPersonOfTheDay) {
+		return person, err
+	}
+
+	// The store lock is re-entrant for this instance, so concurrent requests
+	// of the same replica are serialized here first
+	rotationMutex.Lock()
+	defer rotationMutex.Unlock()
+
+	acquired, err := store.AcquireLock(ctx, rotationLock, lockOwner, rotationLockTTL)
+	if err != nil {
+		return persons.Person{}, err
+	}
+
+	if !acquired {
+		// Another replica is rotating, give it a moment to finish
+		for i := 0; i < 10; i++ {
+			select {
+			case <-ctx.Done():
+				return persons.Person{}, ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+			if person, err = store.GetPersonOfTheDay(ctx, clock.Today()); !errors.Is(err, db.ErrNoPersonOfTheDay) {
+				return person, err
+			}
+		}
+		return persons.Person{}, err
+	}
+
+	// Once the lock is ours the rotation runs to the end even if the request
+	// that triggered it goes away, half a rotation would leave no person
+	ctx = context.WithoutCancel(ctx)
+	defer func() {
+		if err := store.ReleaseLock(ctx, rotationLock, lockOwner); err != nil {
+			slog.ErrorContext(ctx, "Failed to release rotation lock", "error", err)
+		}
+	}()
+
+	// The person may have been created while we were waiting for the lock
+	if person, err = store.GetPersonOfTheDay(ctx, clock.Today()); !errors.Is(err, db.ErrNoPersonOfTheDay) {
+		return person, err
+	}
+
+	slog.DebugContext(ctx, "No person of the day yet, rotating now")
+	if err := UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+		return persons.Person{}, err
+	}
+
+	return store.GetPersonOfTheDay(ctx, clock.Today())
+}
+
+// GetPersonOfYesterday returns the answer of the previous game day
+func GetPersonOfYesterday(ctx context.Context, store db.Store, clock *gameclock.Clock) (persons.Person, error) {
+	return store.GetPersonOfTheDay(ctx, clock.Day(-1))
+}
+
+// GetSchedule returns the upcoming persons of the day, starting today
+func GetSchedule(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string, days int) ([]schedule.Pick, error) {
+	personsAvailable, err := store.GetPersons(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get persons: %w", err)
+	}
+
+	cycle, err := store.GetScheduleCycle(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schedule cycle: %w", err)
+	}
+
+	return schedule.Upcoming(seed, personsAvailable.Persons, cycle, clock.Date(0), days)
+}
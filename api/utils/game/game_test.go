@@ -0,0 +1,278 @@
+package game_test
+
+import (
+	db "api/db"
+	memory "api/db/memory"
+	persons "api/struct"
+	game "api/utils/game"
+	gameclock "api/utils/gameclock"
+	schedule "api/utils/schedule"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+const seed = "test-seed"
+
+var ctx = context.Background()
+
+var catalogue = []persons.Person{
+	{Firstname: "Ada", Lastname: "Lovelace", Gender: "Femme", Type: "DO24-27", Workplace: "CIRAD", Hint: "Analytical engine"},
+	{Firstname: "Alan", Lastname: "Turing", Gender: "Homme", Type: "DO23-26", Workplace: "INRAE", Hint: "Enigma"},
+	{Firstname: "Grace", Lastname: "Hopper", Gender: "Femme", Type: "DO25-28", Workplace: "CIRAD", Hint: "COBOL"},
+	{Firstname: "Linus", Lastname: "Torvalds", Gender: "Homme", Type: "Enseignant", Workplace: "Polytech", Hint: "Penguin"},
+}
+
+// clockAt returns a game clock rolling over at 10:00 UTC that reads now
+func clockAt(now time.Time) *gameclock.Clock {
+	return &gameclock.Clock{Location: time.UTC, RolloverHour: 10, Now: func() time.Time { return now }}
+}
+
+// pick stores the catalogue person as the person of the given day
+func pick(t *testing.T, store db.Store, date string, person persons.Person) {
+	t.Helper()
+	stored, err := store.FindPerson(ctx, persons.Slug(person.Firstname, person.Lastname))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreatePersonOfTheDay(ctx, date, stored); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func picks(t *testing.T, store db.Store, clock *gameclock.Clock) map[string]int {
+	t.Helper()
+	count := map[string]int{}
+	for offset := -15; offset <= 1; offset++ {
+		if _, err := store.GetPersonOfTheDay(ctx, clock.Day(offset)); err == nil {
+			count[clock.Day(offset)]++
+		}
+	}
+	return count
+}
+
+func TestUpdatePersonOfTheDay(t *testing.T) {
+	t.Parallel()
+	clock := clockAt(time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC))
+
+	t.Run("picks today's person from the schedule", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+			t.Fatalf("UpdatePersonOfTheDay() error = %v", err)
+		}
+
+		pool, _ := store.GetPersons(ctx)
+		want, _, err := schedule.Next(seed, pool.Persons, persons.ScheduleCycle{}, clock.Today())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.GetPersonOfTheDay(ctx, clock.Today())
+		if err != nil {
+			t.Fatalf("GetPersonOfTheDay() error = %v", err)
+		}
+		if got.ID != want.ID {
+			t.Errorf("person of the day = %s, want %s", got.ID, want.ID)
+		}
+	})
+
+	t.Run("replaces the person already picked today", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		pick(t, store, clock.Today(), catalogue[3])
+		if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+			t.Fatalf("UpdatePersonOfTheDay() error = %v", err)
+		}
+
+		all, _ := store.GetPersonsOfTheDay(ctx)
+		if len(all) != 1 {
+			t.Fatalf("stored %d persons of the day, want 1", len(all))
+		}
+		if all[0].Hint == "" || all[0].ID == "" {
+			t.Errorf("stored person is incomplete: %+v", all[0])
+		}
+	})
+
+	t.Run("forgets the person of ten days ago only", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		for _, offset := range []int{-10, -9, -1} {
+			pick(t, store, clock.Day(offset), catalogue[0])
+		}
+		if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+			t.Fatalf("UpdatePersonOfTheDay() error = %v", err)
+		}
+
+		got := picks(t, store, clock)
+		for offset, want := range map[int]int{-10: 0, -9: 1, -1: 1, 0: 1} {
+			if got[clock.Day(offset)] != want {
+				t.Errorf("day %d has %d picks, want %d", offset, got[clock.Day(offset)], want)
+			}
+		}
+	})
+
+	t.Run("never picks a deleted person", func(t *testing.T) {
+		clock := clockAt(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+		store := memory.New(clock, catalogue[:2]...)
+		if err := store.SoftDeletePerson(ctx, persons.Slug("Ada", "Lovelace")); err != nil {
+			t.Fatal(err)
+		}
+		// Every day of a cycle must land on the only person left
+		for day := 0; day < 5; day++ {
+			*clock = *clockAt(time.Date(2025, 7, 1+day, 12, 0, 0, 0, time.UTC))
+			if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+				t.Fatalf("UpdatePersonOfTheDay() error = %v", err)
+			}
+			got, _ := store.GetPersonOfTheDay(ctx, clock.Today())
+			if got.ID != persons.Slug("Alan", "Turing") {
+				t.Errorf("day %d picked %s", day, got.ID)
+			}
+		}
+	})
+
+	t.Run("never repeats a person when the pool grows mid-cycle", func(t *testing.T) {
+		clock := clockAt(time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC))
+		store := memory.New(clock, catalogue[:3]...)
+		seen := map[string]bool{}
+		for day := 0; day < 4; day++ {
+			*clock = *clockAt(time.Date(2025, 7, 1+day, 12, 0, 0, 0, time.UTC))
+			if day == 2 {
+				if _, err := store.CreatePerson(ctx, catalogue[3]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+				t.Fatalf("UpdatePersonOfTheDay() error = %v", err)
+			}
+			got, _ := store.GetPersonOfTheDay(ctx, clock.Today())
+			if seen[got.ID] {
+				t.Errorf("day %d picked %s again", day, got.ID)
+			}
+			seen[got.ID] = true
+		}
+	})
+
+	t.Run("fails without persons", func(t *testing.T) {
+		if err := game.UpdatePersonOfTheDay(ctx, memory.New(clock), clock, seed); err == nil {
+			t.Error("UpdatePersonOfTheDay() succeeded with an empty catalogue")
+		}
+	})
+}
+
+func TestUpdatePersonOfTheDayFollowsTheRollover(t *testing.T) {
+	t.Parallel()
+
+	// 09:59 is still the previous game day, 10:00 starts a new one
+	clock := clockAt(time.Date(2025, 6, 30, 9, 59, 0, 0, time.UTC))
+	store := memory.New(clock, catalogue...)
+	if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetPersonOfTheDay(ctx, "2025-06-29"); err != nil {
+		t.Errorf("no person stored for 2025-06-29 before the rollover: %v", err)
+	}
+
+	*clock = *clockAt(time.Date(2025, 6, 30, 10, 0, 0, 0, time.UTC))
+	if err := game.UpdatePersonOfTheDay(ctx, store, clock, seed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetPersonOfTheDay(ctx, "2025-06-30"); err != nil {
+		t.Errorf("no person stored for 2025-06-30 after the rollover: %v", err)
+	}
+}
+
+func TestEnsurePersonOfTheDay(t *testing.T) {
+	t.Parallel()
+	clock := clockAt(time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC))
+
+	t.Run("keeps the stored person", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		pick(t, store, clock.Today(), catalogue[3])
+		got, err := game.EnsurePersonOfTheDay(ctx, store, clock, seed)
+		if err != nil {
+			t.Fatalf("EnsurePersonOfTheDay() error = %v", err)
+		}
+		if got.Lastname != "Torvalds" {
+			t.Errorf("EnsurePersonOfTheDay() = %s, want the stored person", got.Lastname)
+		}
+	})
+
+	t.Run("rotates when today has no person", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		got, err := game.EnsurePersonOfTheDay(ctx, store, clock, seed)
+		if err != nil {
+			t.Fatalf("EnsurePersonOfTheDay() error = %v", err)
+		}
+		again, _ := game.EnsurePersonOfTheDay(ctx, store, clock, seed)
+		if got.ID == "" || again.ID != got.ID {
+			t.Errorf("EnsurePersonOfTheDay() = %q then %q, want one stable person", got.ID, again.ID)
+		}
+	})
+
+	t.Run("waits for the replica holding the lock", func(t *testing.T) {
+		store := memory.New(clock, catalogue...)
+		if _, err := store.AcquireLock(ctx, "person-of-the-day-rotation", "other-replica", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			stored, _ := store.FindPerson(ctx, "grace-hopper")
+			_ = store.CreatePersonOfTheDay(ctx, clock.Today(), stored)
+		}()
+
+		got, err := game.EnsurePersonOfTheDay(ctx, store, clock, seed)
+		if err != nil {
+			t.Fatalf("EnsurePersonOfTheDay() error = %v", err)
+		}
+		if got.Lastname != "Hopper" {
+			t.Errorf("EnsurePersonOfTheDay() = %s, want the other replica's pick", got.Lastname)
+		}
+	})
+}
+
+func TestTryGuess(t *testing.T) {
+	t.Parallel()
+	clock := clockAt(time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC))
+	store := memory.New(clock, catalogue...)
+	pick(t, store, clock.Today(), catalogue[0])
+
+	tests := []struct {
+		name    string
+		guess   persons.GuessRequest
+		correct bool
+		wantErr error
+	}{
+		{"correct id", persons.GuessRequest{ID: "ada-lovelace"}, true, nil},
+		{"correct legacy names", persons.GuessRequest{Firstname: "Ada", Lastname: "Lovelace"}, true, nil},
+		{"wrong person", persons.GuessRequest{ID: "grace-hopper"}, false, nil},
+		{"unknown person", persons.GuessRequest{ID: "nobody"}, false, db.ErrUnknownPerson},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := game.TryGuess(ctx, store, clock, seed, tt.guess)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("TryGuess() error = %v, want %v", err, tt.wantErr)
+			}
+			if result.Correct != tt.correct {
+				t.Errorf("TryGuess() correct = %t, want %t", result.Correct, tt.correct)
+			}
+			if result.Person.Hint != "" || result.Guessed.Hint != "" {
+				t.Error("TryGuess() leaked a hint")
+			}
+		})
+	}
+
+	t.Run("wrong person only reveals matching fields", func(t *testing.T) {
+		result, err := game.TryGuess(ctx, store, clock, seed, persons.GuessRequest{ID: "grace-hopper"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Person.Gender != "Femme" || result.Person.Workplace != "CIRAD" {
+			t.Errorf("matching fields missing: %+v", result.Person)
+		}
+		if result.Person.Firstname != "" || result.Person.Type != "" {
+			t.Errorf("non matching fields revealed: %+v", result.Person)
+		}
+		if result.Hints.Gender != persons.VerdictCorrect {
+			t.Errorf("gender verdict = %s, want %s", result.Hints.Gender, persons.VerdictCorrect)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package gameclock
+
+import (
+	"fmt"
+	"time"
+)
+
+// DateLayout is the format of game day ids, e.g. "2025-06-30"
+const DateLayout = "2006-01-02"
+
+const (
+	DefaultZone         = "Europe/Paris"
+	DefaultRolloverHour = 10
+)
+
+// Clock decides which game day it is. A game day starts at RolloverHour in
+// Location, so every replica agrees on the day whatever the container TZ.
+type Clock struct {
+	Location     *time.Location
+	RolloverHour int
+
+	// Now is replaceable so tests can pin the current time
+	Now func() time.Time
+}
+
+func New(zone string, rolloverHour int) (*Clock, error) {
+	location, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid game time zone %q: %v", zone, err)
+	}
+	if rolloverHour < 0 || rolloverHour > 23 {
+		return nil, fmt.Errorf("invalid rollover hour %d, expected 0 to 23", rolloverHour)
+	}
+	return &Clock{Location: location, RolloverHour: rolloverHour, Now: time.Now}, nil
+}
+
+// Date returns the game day offset days from today, as midnight UTC of that
+// calendar date
+func (c *Clock) Date(offset int) time.Time {
+	now := c.Now().In(c.Location)
+	if now.Hour() < c.RolloverHour {
+		offset--
+	}
+	return time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, time.UTC)
+}
+
+// Day returns the id of the game day offset days from today
+func (c *Clock) Day(offset int) string {
+	return c.Date(offset).Format(DateLayout)
+}
+
+func (c *Clock) Today() string {
+	return c.Day(0)
+}
+
+// NextRollover returns when the next game day starts
+func (c *Clock) NextRollover() time.Time {
+	now := c.Now().In(c.Location)
+	next := time.Date(now.Year(), now.Month(), now.Day(), c.RolloverHour, 0, 0, 0, c.Location)
+	if !next.After(now) {
+		next = time.Date(now.Year(), now.Month(), now.Day()+1, c.RolloverHour, 0, 0, 0, c.Location)
+	}
+	return next
+}
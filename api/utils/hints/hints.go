@@ -0,0 +1,115 @@
+package hints
+
+import (
+	persons "api/struct"
+	"strings"
+)
+
+// Words too common in names and company names to count as a partial match
+var stopWords = map[string]bool{
+	"de": true, "du": true, "des": true, "la": true, "le": true, "les": true,
+	"et": true, "and": true, "the": true, "of": true,
+	"group": true, "groupe": true, "solutions": true, "services": true,
+}
+
+// Compare builds the per-field verdicts of a guess against the answer. Both
+// persons are migrated to the current schema first so older documents get
+// their derived attributes.
+func Compare(answer persons.Person, guess persons.Person) persons.Hints {
+	answer.Migrate()
+	guess.Migrate()
+
+	return persons.Hints{
+		Firstname:      compareText(answer.Firstname, guess.Firstname),
+		Lastname:       compareText(answer.Lastname, guess.Lastname),
+		Gender:         compareExact(answer.Gender, guess.Gender),
+		Type:           compareType(answer, guess),
+		Workplace:      compareText(answer.Workplace, guess.Workplace),
+		Track:          compareKnownText(answer.Track, guess.Track),
+		PromotionStart: compareKnownYear(answer.PromotionStart, guess.PromotionStart),
+		PromotionEnd:   compareKnownYear(answer.PromotionEnd, guess.PromotionEnd),
+	}
+}
+
+func normalize(value string) string {
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+func compareExact(answer string, guess string) persons.Verdict {
+	if normalize(answer) == normalize(guess) {
+		return persons.VerdictCorrect
+	}
+	return persons.VerdictWrong
+}
+
+// compareKnownText compares optional attributes, giving no verdict when one
+// side is unknown
+func compareKnownText(answer string, guess string) persons.Verdict {
+	if normalize(answer) == "" || normalize(guess) == "" {
+		return ""
+	}
+	return compareExact(answer, guess)
+}
+
+func compareKnownYear(answer int, guess int) persons.Verdict {
+	if answer == 0 || guess == 0 {
+		return ""
+	}
+	return compareOrdered(answer, guess)
+}
+
+// compareText reports a partial match when both values share a significant
+// word, e.g. "Giada Flora" and "Flora" or "La Poste" and "La Poste Mobile"
+func compareText(answer string, guess string) persons.Verdict {
+	if normalize(answer) == normalize(guess) {
+		return persons.VerdictCorrect
+	}
+
+	answerWords := words(answer)
+	for word := range words(guess) {
+		if answerWords[word] {
+			return persons.VerdictPartial
+		}
+	}
+	return persons.VerdictWrong
+}
+
+func words(value string) map[string]bool {
+	result := map[string]bool{}
+	for _, word := range strings.FieldsFunc(normalize(value), func(r rune) bool {
+		return r == ' ' || r == '-' || r == '\'' || r == ','
+	}) {
+		if len([]rune(word)) >= 3 && !stopWords[word] {
+			result[word] = true
+		}
+	}
+	return result
+}
+
+// compareType orders promotions by start year. Types that are not a
+// promotion (teachers, speakers) fall back to an exact comparison.
+func compareType(answer persons.Person, guess persons.Person) persons.Verdict {
+	if normalize(answer.Type) == normalize(guess.Type) {
+		return persons.VerdictCorrect
+	}
+
+	if answer.PromotionStart == 0 || guess.PromotionStart == 0 {
+		return persons.VerdictWrong
+	}
+
+	// Same year on another track is close but not the same promotion
+	if answer.PromotionStart == guess.PromotionStart {
+		return persons.VerdictPartial
+	}
+	return compareOrdered(answer.PromotionStart, guess.PromotionStart)
+}
+
+func compareOrdered(answer int, guess int) persons.Verdict {
+	switch {
+	case answer > guess:
+		return persons.VerdictHigher
+	case answer < guess:
+		return persons.VerdictLower
+	}
+	return persons.VerdictCorrect
+}
@@ -0,0 +1,133 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register decoders for the accepted upload formats
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+)
+
+const (
+	// MaxUploadBytes is the largest portrait accepted for upload
+	MaxUploadBytes = 5 << 20
+	// maxPixels guards against small files that decode to huge images
+	maxPixels = 25_000_000
+)
+
+// Sizes are the square thumbnails generated for every portrait, in pixels
+var Sizes = map[string]int{
+	"small": 64,
+	"large": 256,
+}
+
+const DefaultSize = "large"
+
+var (
+	ErrTooLarge         = fmt.Errorf("image is larger than %d MB", MaxUploadBytes>>20)
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+)
+
+var acceptedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+// Process validates an uploaded portrait and renders every thumbnail size.
+// Images are re-encoded as JPEG, which also drops any embedded metadata.
+func Process(r io.Reader) (map[string][]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, MaxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(content) > MaxUploadBytes {
+		return nil, ErrTooLarge
+	}
+
+	// Trust the bytes, not the declared content type
+	if !acceptedTypes[http.DetectContentType(content)] {
+		return nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is %dx%d, at most %d pixels are allowed", config.Width, config.Height, maxPixels)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(content))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	thumbnails := map[string][]byte{}
+	for name, size := range Sizes {
+		var buffer bytes.Buffer
+		if err := jpeg.Encode(&buffer, Thumbnail(source, size), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, fmt.Errorf("failed to encode thumbnail: %v", err)
+		}
+		thumbnails[name] = buffer.Bytes()
+	}
+
+	return thumbnails, nil
+}
+
+// Thumbnail crops the centre square of the image and scales it to size x size.
+// Each output pixel averages the source pixels it covers.
+func Thumbnail(source image.Image, size int) *image.RGBA {
+	bounds := source.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	left := bounds.Min.X + (bounds.Dx()-side)/2
+	top := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, side)
+
+			var r, g, b, a, count uint64
+			for sy := top + y0; sy < top+y1; sy++ {
+				for sx := left + x0; sx < left+x1; sx++ {
+					pr, pg, pb, pa := source.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			// Flatten transparency onto white, JPEG has no alpha channel.
+			// Colors from RGBA() are alpha-premultiplied.
+			background := 0xffff - a/count
+			thumbnail.Set(x, y, color.RGBA64{
+				R: uint16(r/count + background),
+				G: uint16(g/count + background),
+				B: uint16(b/count + background),
+				A: 0xffff,
+			})
+		}
+	}
+
+	return thumbnail
+}
+
+// span returns the source range covered by output pixel i, always at least
+// one pixel wide so small images are upscaled
+func span(i int, size int, side int) (int, int) {
+	start := i * side / size
+	end := (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
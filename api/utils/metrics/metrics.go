@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "dodle"
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests answered, by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time spent answering HTTP requests, by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// Guesses counts evaluated guesses; result="correct" are the solves
+	Guesses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "guesses_total",
+		Help:      "Guesses submitted, by result (correct or incorrect).",
+	}, []string{"result"})
+
+	// AttemptsToSolve gives the average attempts to solve as sum / count
+	AttemptsToSolve = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "attempts_to_solve",
+		Help:      "Guesses a player needed to find the person of the day.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 10),
+	})
+
+	HintRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "hint_requests_total",
+		Help:      "Hint requests, by result (revealed or locked).",
+	}, []string{"result"})
+
+	Rotations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rotations_total",
+		Help:      "Person of the day rotations, by result (success or failure).",
+	}, []string{"result"})
+
+	// RateLimited counts the requests answered 429, by action and by whether
+	// the client IP or the player ran out of tokens
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_total",
+		Help:      "Requests rejected by rate limiting, by action and key (ip or player).",
+	}, []string{"action", "key"})
+
+	MongoOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_operation_duration_seconds",
+		Help:      "Time spent in MongoDB commands, by command and result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "result"})
+)
+
+// Result labels shared by the counters above
+const (
+	ResultCorrect   = "correct"
+	ResultIncorrect = "incorrect"
+	ResultRevealed  = "revealed"
+	ResultLocked    = "locked"
+	ResultSuccess   = "success"
+	ResultFailure   = "failure"
+)
+
+// Handler serves every metric in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	persons "api/struct"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy says which browser origins may call a group of routes. The zero
+// policy keeps the routes same-origin: browsers refuse to let other sites
+// read their responses and preflights are rejected.
+type CORSPolicy struct {
+	// Origins allowed to call the routes, "*" allowing any origin
+	Origins []string
+
+	// Credentials lets the allowed origins send cookies, never granted to "*"
+	Credentials bool
+
+	// Headers are the request headers browsers may send, ExposeHeaders the
+	// response headers scripts may read
+	Headers       []string
+	ExposeHeaders []string
+
+	// MaxAge is how long browsers may cache a preflight answer
+	MaxAge time.Duration
+}
+
+// allows reports whether origin may call the routes, and whether it is only
+// through the "*" wildcard
+func (p CORSPolicy) allows(origin string) (allowed bool, wildcard bool) {
+	for _, candidate := range p.Origins {
+		if candidate == origin {
+			return true, false
+		}
+		if candidate == "*" {
+			wildcard = true
+		}
+	}
+	return wildcard, wildcard
+}
+
+// CORS applies policy to a route handling methods, and answers its
+// preflight requests
+func CORS(policy CORSPolicy, methods []string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The answer depends on the origin, caches must not mix them up
+			w.Header().Add("Vary", "Origin")
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			allowed, wildcard := policy.allows(origin)
+			if !allowed {
+				if preflight {
+					WriteError(w, r, http.StatusForbidden, persons.CodeForbidden, "Origin not allowed")
+					return
+				}
+				// Same-origin requests send an Origin too, they are served
+				// but a browser on another site cannot read the answer
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if policy.Credentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+
+			if !preflight {
+				if len(policy.ExposeHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(policy.Headers) > 0 {
+				w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.Headers, ", "))
+			}
+			if policy.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(policy.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
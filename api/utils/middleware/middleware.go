@@ -0,0 +1,242 @@
+package middleware
+
+import (
+	persons "api/struct"
+	metrics "api/utils/metrics"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"time"
+)
+
+// RequestIDHeader carries the id of a request, either forwarded by a proxy or
+// generated by the API, so a client report can be matched with the logs
+const RequestIDHeader = "X-Request-ID"
+
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware wraps a handler with extra behaviour
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler with the middlewares, the first one being the outermost
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+type contextKey string
+
+const infoKey contextKey = "requestInfo"
+
+// info is filled along the way by the handlers and read back by the access log
+type info struct {
+	RequestID string
+	Route     string
+	PlayerID  string
+}
+
+func infoFrom(ctx context.Context) *info {
+	if i, ok := ctx.Value(infoKey).(*info); ok {
+		return i
+	}
+	return &info{}
+}
+
+// RequestIDFrom returns the id of the request, or an empty string outside of
+// the RequestID middleware
+func RequestIDFrom(ctx context.Context) string {
+	return infoFrom(ctx).RequestID
+}
+
+// WriteError answers with the JSON error envelope of the API, the one
+// response every route and middleware uses for errors
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(persons.ErrorResponse{
+		Code:      code,
+		Message:   message,
+		RequestID: RequestIDFrom(r.Context()),
+	}); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to encode error response", "error", err)
+	}
+}
+
+// SetPlayer records which player made the request for the access log. The
+// id must not be a credential, such as a raw session id.
+func SetPlayer(r *http.Request, playerID string) {
+	infoFrom(r.Context()).PlayerID = playerID
+}
+
+func newRequestID() string {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(raw)
+}
+
+// RequestID reuses a well formed incoming X-Request-ID or generates one, and
+// sends it back in the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !requestIDPattern.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), infoKey, &info{RequestID: requestID})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Route names the route of the request in the access log, so ids in the path
+// do not end up as separate routes
+func Route(pattern string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			infoFrom(r.Context()).Route = pattern
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// statusRecorder remembers the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// AccessLog writes one structured line per request once it is served
+func AccessLog(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status == 0 {
+				recorder.status = http.StatusOK
+			}
+			i := infoFrom(r.Context())
+			route := routeOf(i)
+
+			level := slog.LevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			logger.LogAttrs(r.Context(), level, "request",
+				slog.String("request_id", i.RequestID),
+				slog.String("method", r.Method),
+				slog.String("route", route),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", recorder.status),
+				slog.Int("bytes", recorder.bytes),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("player_id", i.PlayerID),
+			)
+		})
+	}
+}
+
+// routeOf returns the route of the request, naming the paths no route
+// matched as a single route
+func routeOf(i *info) string {
+	if i.Route == "" {
+		return "unmatched"
+	}
+	return i.Route
+}
+
+// knownMethods keeps the method label of the metrics bounded whatever
+// clients send
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Metrics counts requests and measures their latency per route
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		method := r.Method
+		if !knownMethods[method] {
+			method = "other"
+		}
+		route := routeOf(infoFrom(r.Context()))
+
+		metrics.HTTPRequests.WithLabelValues(route, method, strconv.Itoa(recorder.status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Recover turns a panic in a handler into a JSON 500 instead of dropping the
+// connection, and logs the stack trace
+func Recover(logger *slog.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				// Let the server abort the response as it normally would
+				if recovered == http.ErrAbortHandler {
+					panic(recovered)
+				}
+
+				requestID := RequestIDFrom(r.Context())
+				logger.ErrorContext(r.Context(), "panic while serving request",
+					slog.String("request_id", requestID),
+					slog.Any("panic", recovered),
+					slog.String("stack", string(debug.Stack())),
+				)
+
+				WriteError(w, r, http.StatusInternalServerError, persons.CodeInternal, "Internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
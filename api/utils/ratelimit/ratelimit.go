@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Rate lets Burst requests through at once, then one more every Interval.
+// A zero Burst disables the limit.
+type Rate struct {
+	Burst    int
+	Interval time.Duration
+}
+
+// Buckets holds a token bucket per key. MongoDB buckets are shared by every
+// API replica, in-memory ones only limit the replica they live in.
+type Buckets interface {
+	// TakeToken takes a token from the bucket of key, which holds up to burst
+	// tokens and gains one every interval. It returns zero when a token was
+	// taken, or how long until one is available.
+	TakeToken(ctx context.Context, key string, burst int, interval time.Duration) (time.Duration, error)
+}
+
+// Take takes a token for key at the given rate
+func Take(ctx context.Context, buckets Buckets, key string, rate Rate) (time.Duration, error) {
+	if rate.Burst <= 0 {
+		return 0, nil
+	}
+	return buckets.TakeToken(ctx, key, rate.Burst, rate.Interval)
+}
+
+// Refill returns the tokens of a bucket once elapsed has passed
+func Refill(tokens float64, elapsed time.Duration, burst int, interval time.Duration) float64 {
+	tokens += float64(elapsed) / float64(interval)
+	if tokens > float64(burst) {
+		return float64(burst)
+	}
+	return tokens
+}
+
+// Wait returns how long a bucket holding tokens needs to gain a whole token
+func Wait(tokens float64, interval time.Duration) time.Duration {
+	return time.Duration((1 - tokens) * float64(interval))
+}
+
+// sweepEvery is how often Memory forgets the buckets that are full again
+const sweepEvery = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+// Memory keeps the buckets in the process
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+
+	// Now is the clock of the buckets, replaced in tests
+	Now func() time.Time
+}
+
+var _ Buckets = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]*bucket{}, Now: time.Now}
+}
+
+func (m *Memory) TakeToken(ctx context.Context, key string, burst int, interval time.Duration) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = Refill(b.tokens, now.Sub(b.updated), burst, interval)
+	b.updated = now
+
+	if b.tokens < 1 {
+		return Wait(b.tokens, interval), nil
+	}
+	b.tokens--
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) * float64(interval)))
+	return 0, nil
+}
+
+// sweep drops the buckets that refilled, a missing bucket being a full one
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < sweepEvery {
+		return
+	}
+	m.swept = now
+	for key, b := range m.buckets {
+		if !now.Before(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit_test
+
+import (
+	ratelimit "api/utils/ratelimit"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTakeToken(t *testing.T) {
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	buckets := ratelimit.NewMemory()
+	buckets.Now = func() time.Time { return now }
+	rate := ratelimit.Rate{Burst: 3, Interval: 2 * time.Second}
+
+	take := func(key string) time.Duration {
+		t.Helper()
+		wait, err := ratelimit.Take(context.Background(), buckets, key, rate)
+		if err != nil {
+			t.Fatalf("Take(%s) error = %v", key, err)
+		}
+		return wait
+	}
+
+	for i := 0; i < rate.Burst; i++ {
+		if wait := take("a"); wait != 0 {
+			t.Fatalf("take %d waits %s within the burst", i+1, wait)
+		}
+	}
+	if wait := take("a"); wait != 2*time.Second {
+		t.Errorf("empty bucket waits %s, want 2s", wait)
+	}
+	if wait := take("b"); wait != 0 {
+		t.Errorf("other key waits %s, want its own bucket", wait)
+	}
+
+	now = now.Add(1500 * time.Millisecond)
+	if wait := take("a"); wait != 500*time.Millisecond {
+		t.Errorf("refilling bucket waits %s, want 500ms", wait)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if wait := take("a"); wait != 0 {
+		t.Errorf("refilled bucket waits %s, want a token", wait)
+	}
+
+	// Well past a full refill, the swept bucket starts full again
+	now = now.Add(time.Hour)
+	for i := 0; i < rate.Burst; i++ {
+		if wait := take("a"); wait != 0 {
+			t.Fatalf("take %d after refill waits %s", i+1, wait)
+		}
+	}
+}
+
+func TestDisabledRate(t *testing.T) {
+	buckets := ratelimit.NewMemory()
+	for i := 0; i < 100; i++ {
+		if wait, _ := ratelimit.Take(context.Background(), buckets, "a", ratelimit.Rate{}); wait != 0 {
+			t.Fatalf("disabled rate waits %s", wait)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package rotation
+
+import (
+	db "api/db"
+	game "api/utils/game"
+	gameclock "api/utils/gameclock"
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Retries of a failed rotation start after firstRetryDelay and back off up
+// to maxRetryDelay, so a database blip at the rollover does not leave the
+// day without a person until the next rollover
+const (
+	firstRetryDelay = 5 * time.Second
+	maxRetryDelay   = 5 * time.Minute
+)
+
+func rotate(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string) {
+	delay := firstRetryDelay
+	for {
+		_, err := game.EnsurePersonOfTheDay(ctx, store, clock, seed)
+		if err == nil {
+			slog.InfoContext(ctx, "Daily rotation done")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.ErrorContext(ctx, "Daily rotation failed", "retry_in", delay.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// waitUntil sleeps until the game clock reads at or after t. The wall clock
+// may lag the timer, so it waits again rather than rotating a day that has
+// not started yet. It returns false when ctx is cancelled first.
+func waitUntil(ctx context.Context, clock *gameclock.Clock, t time.Time) bool {
+	for {
+		wait := t.Sub(clock.Now())
+		if wait <= 0 {
+			return true
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// Start runs the daily rotation in the background until ctx is cancelled,
+// firing at the rollover hour of the game clock, the very moment a new game
+// day starts, so the scheduled job and not a request picks the new person.
+// It also rotates right away in case the API starts after the rollover.
+// Every replica runs the job; the rotation lock makes only one of them pick.
+func Start(ctx context.Context, store db.Store, clock *gameclock.Clock, seed string) {
+	go func() {
+		rotate(ctx, store, clock, seed)
+
+		for {
+			next := clock.NextRollover()
+			slog.InfoContext(ctx, "Next person of the day rotation", "at", next.Format(time.RFC3339))
+
+			if !waitUntil(ctx, clock, next) {
+				return
+			}
+			rotate(ctx, store, clock, seed)
+		}
+	}()
+}
@@ -0,0 +1,44 @@
+package rotation_test
+
+import (
+	memory "api/db/memory"
+	persons "api/struct"
+	gameclock "api/utils/gameclock"
+	rotation "api/utils/rotation"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStartRotatesAtTheRollover(t *testing.T) {
+	// The game clock reads 200ms before the 10:00 rollover when the job starts
+	started := time.Now()
+	base := time.Date(2025, 6, 30, 9, 59, 59, 800_000_000, time.UTC)
+	clock := &gameclock.Clock{Location: time.UTC, RolloverHour: 10, Now: func() time.Time {
+		return base.Add(time.Since(started))
+	}}
+	store := memory.New(clock,
+		persons.Person{Firstname: "Ada", Lastname: "Lovelace"},
+		persons.Person{Firstname: "Alan", Lastname: "Turing"},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rotation.Start(ctx, store, clock, "test-seed")
+
+	// Only the job rotates here, no request could create the new day's person
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if _, err := store.GetPersonOfTheDay(ctx, "2025-06-30"); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no person picked for 2025-06-30 after the rollover")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := store.GetPersonOfTheDay(ctx, "2025-06-29"); err != nil {
+		t.Errorf("no person picked for 2025-06-29 on start: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package routes
+
+import (
+	persons "api/struct"
+	apisecurity "api/utils/apisecurity"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// requireScope checks the API key of a private route. On failure an error
+// response has already been written and false is returned.
+func (s *Server) requireScope(w http.ResponseWriter, r *http.Request, scope string) bool {
+	err := apisecurity.Authorize(r, s.Store, s.Config.APIToken, scope)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, apisecurity.ErrForbidden):
+		writeError(w, r, http.StatusForbidden, persons.CodeForbidden, "Forbidden: this key needs the "+scope+" scope")
+	default:
+		writeFailure(w, r, err, "Failed to check api key")
+	}
+	return false
+}
+
+func validateAPIKeyRequest(request persons.APIKeyRequest) error {
+	var problems []string
+	if name := strings.TrimSpace(request.Name); name == "" || len(name) > 64 {
+		problems = append(problems, "name is required and at most 64 characters")
+	}
+	if len(request.Scopes) == 0 {
+		problems = append(problems, "at least one scope is required")
+	}
+	for _, scope := range request.Scopes {
+		known := false
+		for _, s := range persons.Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			problems = append(problems, "unknown scope "+scope+", expected one of "+strings.Join(persons.Scopes, ", "))
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		problems = append(problems, "expires_at must be in the future")
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Route : /private/v1/keys
+func (s *Server) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManageKeys) {
+		return
+	}
+
+	keys, err := s.Store.ListAPIKeys(r.Context())
+	if err != nil {
+		writeFailure(w, r, err, "Failed to list api keys")
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// Route : /private/v1/keys
+// Mints a key and returns its secret, the only time it is shown
+func (s *Server) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManageKeys) {
+		return
+	}
+
+	var request persons.APIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeBadRequest(w, r, "Invalid request body: "+err.Error())
+		return
+	}
+	if err := validateAPIKeyRequest(request); err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+
+	key, apiKey, err := s.Store.CreateAPIKey(r.Context(), request)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to create api key")
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+// Route : /private/v1/keys/{id}
+func (s *Server) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManageKeys) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil {
+		writeNotFound(w, r)
+		return
+	}
+
+	if err := s.Store.RevokeAPIKey(r.Context(), id); err != nil {
+		writeFailure(w, r, err, "Failed to revoke api key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
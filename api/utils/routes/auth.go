@@ -0,0 +1,84 @@
+package routes
+
+import (
+	db "api/db"
+	persons "api/struct"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{3,32}$`)
+
+func validateCredentials(credentials persons.Credentials) error {
+	if !usernamePattern.MatchString(credentials.Username) {
+		return fmt.Errorf("username must be 3 to 32 characters among letters, digits, '_', '.' and '-'")
+	}
+	// bcrypt ignores everything past 72 bytes
+	if len(credentials.Password) < 8 || len(credentials.Password) > 72 {
+		return fmt.Errorf("password must be between 8 and 72 characters")
+	}
+	return nil
+}
+
+func writeSession(w http.ResponseWriter, status int, token string, user persons.User) {
+	writeJSON(w, status, map[string]interface{}{
+		"token":      token,
+		"expires_in": int(db.SessionDuration.Seconds()),
+		"user":       user,
+	})
+}
+
+// Route : /public/v1/auth/register
+func (s *Server) Register(w http.ResponseWriter, r *http.Request) {
+
+	var credentials persons.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeBadRequest(w, r, "Invalid request body: "+err.Error())
+		return
+	}
+
+	if err := validateCredentials(credentials); err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+
+	user, err := s.Store.RegisterUser(r.Context(), credentials)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to register user")
+		return
+	}
+
+	token, err := s.Store.CreateSession(r.Context(), user.ID)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to create session")
+		return
+	}
+
+	writeSession(w, http.StatusCreated, token, user)
+}
+
+// Route : /public/v1/auth/login
+func (s *Server) Login(w http.ResponseWriter, r *http.Request) {
+
+	var credentials persons.Credentials
+	if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil {
+		writeBadRequest(w, r, "Invalid request body: "+err.Error())
+		return
+	}
+
+	user, err := s.Store.AuthenticateUser(r.Context(), credentials)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to log in")
+		return
+	}
+
+	token, err := s.Store.CreateSession(r.Context(), user.ID)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to create session")
+		return
+	}
+
+	writeSession(w, http.StatusOK, token, user)
+}
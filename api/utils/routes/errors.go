@@ -0,0 +1,87 @@
+package routes
+
+import (
+	db "api/db"
+	persons "api/struct"
+	apisecurity "api/utils/apisecurity"
+	images "api/utils/images"
+	middleware "api/utils/middleware"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// domainErrors maps the errors a client can act upon to their response.
+// Anything else is reported as an internal error without its details.
+var domainErrors = []struct {
+	err     error
+	status  int
+	code    string
+	message string
+}{
+	{db.ErrNoPersonOfTheDay, http.StatusNotFound, persons.CodeNoPersonOfTheDay, "No person of the day yet"},
+	{db.ErrUnknownPerson, http.StatusBadRequest, persons.CodeUnknownPerson, "Unknown person"},
+	{db.ErrPersonExists, http.StatusConflict, persons.CodePersonExists, "A person with this id already exists"},
+	{db.ErrInvalidSession, http.StatusUnauthorized, persons.CodeInvalidSession, "Invalid or expired session"},
+	{db.ErrInvalidCredentials, http.StatusUnauthorized, persons.CodeInvalidCredentials, "Invalid username or password"},
+	{db.ErrUsernameTaken, http.StatusConflict, persons.CodeUsernameTaken, "Username already taken"},
+	{db.ErrInvalidPeriod, http.StatusBadRequest, persons.CodeBadRequest, "Period must be one of day, week or month"},
+	{db.ErrNoPortrait, http.StatusNotFound, persons.CodeNotFound, "No portrait for this person"},
+	{db.ErrUnknownAPIKey, http.StatusNotFound, persons.CodeNotFound, "Api key not found or already revoked"},
+	{apisecurity.ErrUnauthorized, http.StatusUnauthorized, persons.CodeUnauthorized, "Unauthorized"},
+	{apisecurity.ErrForbidden, http.StatusForbidden, persons.CodeForbidden, "Forbidden"},
+	{images.ErrTooLarge, http.StatusRequestEntityTooLarge, persons.CodeTooLarge, images.ErrTooLarge.Error()},
+	{images.ErrUnsupportedImage, http.StatusUnsupportedMediaType, persons.CodeUnsupportedMedia, images.ErrUnsupportedImage.Error()},
+}
+
+// writeError answers with the JSON error envelope shared with the middlewares
+var writeError = middleware.WriteError
+
+// writeFailure answers with the response of a domain error, or logs err and
+// answers "<action>" as an internal error. A database that did not answer
+// in time is unavailable rather than broken, the client may retry.
+func writeFailure(w http.ResponseWriter, r *http.Request, err error, action string) {
+	if db.IsTimeout(err) || errors.Is(err, context.Canceled) {
+		slog.WarnContext(r.Context(), action, "request_id", middleware.RequestIDFrom(r.Context()), "error", err)
+		writeError(w, r, http.StatusServiceUnavailable, persons.CodeUnavailable, "The database did not answer in time, try again")
+		return
+	}
+
+	var validationErr *persons.ValidationError
+	if errors.As(err, &validationErr) {
+		writeError(w, r, http.StatusBadRequest, persons.CodeValidation, err.Error())
+		return
+	}
+
+	for _, known := range domainErrors {
+		if errors.Is(err, known.err) {
+			writeError(w, r, known.status, known.code, known.message)
+			return
+		}
+	}
+
+	slog.ErrorContext(r.Context(), action, "request_id", middleware.RequestIDFrom(r.Context()), "error", err)
+	writeError(w, r, http.StatusInternalServerError, persons.CodeInternal, action)
+}
+
+// writeBadRequest answers a malformed request
+func writeBadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	writeError(w, r, http.StatusBadRequest, persons.CodeBadRequest, message)
+}
+
+// writeNotFound answers a route or resource that does not exist
+func writeNotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, http.StatusNotFound, persons.CodeNotFound, "Not found")
+}
+
+// writeMethodNotAllowed answers a method the route does not handle
+func writeMethodNotAllowed(w http.ResponseWriter, r *http.Request, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, r, http.StatusMethodNotAllowed, persons.CodeMethodNotAllowed, "Method not allowed")
+}
+
+// NotFound answers every path no route matches
+func (s *Server) NotFound(w http.ResponseWriter, r *http.Request) {
+	writeNotFound(w, r)
+}
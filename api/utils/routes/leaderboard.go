@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// queryInt reads a positive integer query parameter, falling back to def
+// when it is missing
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, errors.New(name + " must be a positive integer")
+	}
+	return value, nil
+}
+
+// Route : /public/v1/leaderboard?period=day|week|month&page=1&page_size=20
+func (s *Server) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "day"
+	}
+
+	page, err := queryInt(r, "page", 1)
+	if err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+	pageSize, err := queryInt(r, "page_size", defaultPageSize)
+	if err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	// Past this page the offset of its first entry overflows
+	if page > math.MaxInt/pageSize {
+		writeBadRequest(w, r, "page is too large")
+		return
+	}
+
+	leaderboard, err := s.Store.GetLeaderboard(r.Context(), period, page, pageSize)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get leaderboard")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, leaderboard)
+}
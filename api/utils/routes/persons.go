@@ -0,0 +1,295 @@
+package routes
+
+import (
+	db "api/db"
+	persons "api/struct"
+	images "api/utils/images"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	// The status is already sent, all that is left is to log
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		slog.Error("Failed to encode response", "error", err)
+	}
+}
+
+// writePersonError reports catalogue errors. An unknown person is a missing
+// resource here rather than a bad guess.
+func writePersonError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, db.ErrUnknownPerson) {
+		writeError(w, r, http.StatusNotFound, persons.CodeNotFound, "Person not found")
+		return
+	}
+	writeFailure(w, r, err, "Failed to update persons")
+}
+
+func decodePerson(r *http.Request) (persons.Person, error) {
+	var person persons.Person
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&person); err != nil {
+		return persons.Person{}, &persons.ValidationError{Problems: []string{"invalid request body: " + err.Error()}}
+	}
+	return person, nil
+}
+
+// Route : /private/v1/persons?include_deleted=true
+// Lists the catalogue, with soft-deleted persons on request
+func (s *Server) ListPersons(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	list, err := s.Store.ListPersons(r.Context(), r.URL.Query().Get("include_deleted") == "true")
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, persons.Persons{Persons: list})
+}
+
+// Route : /private/v1/persons
+func (s *Server) CreatePerson(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	person, err := decodePerson(r)
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	if person.ID == "" {
+		person.ID = persons.Slug(person.Firstname, person.Lastname)
+	}
+	if err := person.Validate(); err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+
+	created, err := s.Store.CreatePerson(r.Context(), person)
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// Route : /private/v1/persons/{id}
+func (s *Server) GetPerson(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	person, err := s.Store.FindPerson(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, person)
+}
+
+// Route : /private/v1/persons/{id}
+// Replaces the person, whose id cannot change
+func (s *Server) UpdatePerson(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	id := r.PathValue("id")
+	person, err := decodePerson(r)
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	if person.ID != "" && person.ID != id {
+		writeBadRequest(w, r, "The id of a person cannot be changed")
+		return
+	}
+	person.ID = id
+	if err := person.Validate(); err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+
+	updated, err := s.Store.UpdatePerson(r.Context(), person)
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, updated)
+}
+
+// Route : /private/v1/persons/{id}
+// Soft-deletes the person, past games keep referencing it
+func (s *Server) DeletePerson(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	if err := s.Store.SoftDeletePerson(r.Context(), r.PathValue("id")); err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Route : /private/v1/persons/{id}/restore
+// Brings a soft-deleted person back
+func (s *Server) RestorePerson(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	if err := s.Store.RestorePerson(r.Context(), r.PathValue("id")); err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Route : /private/v1/persons/{id}/image
+// Uploads a portrait, sent either as the "image" field of a multipart form
+// or as the raw request body
+func (s *Server) UploadPortrait(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	// Leave room for the multipart envelope around the image
+	r.Body = http.MaxBytesReader(w, r.Body, images.MaxUploadBytes+64<<10)
+
+	var upload io.Reader = r.Body
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("image")
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeFailure(w, r, images.ErrTooLarge, "Failed to process image")
+			return
+		}
+		if err != nil {
+			writeBadRequest(w, r, "Missing image field: "+err.Error())
+			return
+		}
+		defer func() {
+			if err := file.Close(); err != nil {
+				slog.WarnContext(r.Context(), "Failed to close uploaded file", "error", err)
+			}
+		}()
+		upload = file
+	}
+
+	thumbnails, err := images.Process(upload)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeFailure(w, r, images.ErrTooLarge, "Failed to process image")
+		return
+	case errors.Is(err, images.ErrTooLarge), errors.Is(err, images.ErrUnsupportedImage):
+		writeFailure(w, r, err, "Failed to process image")
+		return
+	case err != nil:
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+
+	person, err := s.Store.SavePortrait(r.Context(), r.PathValue("id"), thumbnails)
+	if err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, person)
+}
+
+// Route : /private/v1/persons/{id}/image
+func (s *Server) DeletePortrait(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeManagePersons) {
+		return
+	}
+
+	if err := s.Store.DeletePortrait(r.Context(), r.PathValue("id")); err != nil {
+		writePersonError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Route : /public/v1/persons/{id}/image?size=small|large
+func (s *Server) GetPersonImage(w http.ResponseWriter, r *http.Request) {
+
+	size := r.URL.Query().Get("size")
+	if size == "" {
+		size = images.DefaultSize
+	}
+	if _, ok := images.Sizes[size]; !ok {
+		writeBadRequest(w, r, "size must be small or large")
+		return
+	}
+
+	// The image URL stays the same when a new portrait is uploaded, so caches
+	// keep it briefly and then revalidate against the content hash ETag
+	w.Header().Set("Cache-Control", "public, max-age=300, must-revalidate")
+
+	// Revalidations are answered from the stored ETag, without reading the
+	// image itself
+	stored, err := s.Store.FindPortrait(r.Context(), r.PathValue("id"), size)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get portrait")
+		return
+	}
+	if stored.ETag != "" && etagMatches(r.Header.Get("If-None-Match"), stored.ETag) {
+		w.Header().Set("ETag", stored.ETag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	portrait, err := s.Store.GetPortrait(r.Context(), r.PathValue("id"), size)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get portrait")
+		return
+	}
+
+	// ServeContent still answers the conditional requests left, by date
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("ETag", portrait.ETag)
+	http.ServeContent(w, r, "", portrait.UpdatedAt, bytes.NewReader(portrait.Content))
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, comparing
+// weakly as the header requires
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	persons "api/struct"
+	apisecurity "api/utils/apisecurity"
+	middleware "api/utils/middleware"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// SessionIDHeader carries the anonymous session id of players who are not
+// logged in. The API issues one on the first guess and the client sends it back.
+const SessionIDHeader = "X-Session-ID"
+
+var sessionIDPattern = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
+func newSessionID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// fingerprint identifies a session id or token in the logs and the rate
+// limiter without revealing it: whoever holds one can play as its player
+func fingerprint(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:8])
+}
+
+// resolvePlayer identifies the player behind the request. A valid session
+// token wins; otherwise the anonymous session id is used, and a new one is
+// issued when create is true and the request has none. On failure an error
+// response has already been written and ok is false.
+func (s *Server) resolvePlayer(w http.ResponseWriter, r *http.Request, create bool) (player persons.Player, ok bool) {
+	if token := apisecurity.SessionToken(r); token != "" {
+		user, err := s.Store.GetUserBySession(r.Context(), token)
+		if err != nil {
+			writeFailure(w, r, err, "Failed to resolve session")
+			return persons.Player{}, false
+		}
+		middleware.SetPlayer(r, "user:"+user.ID.Hex())
+		return persons.Player{UserID: &user.ID}, true
+	}
+
+	sessionID := r.Header.Get(SessionIDHeader)
+	if sessionID != "" && !sessionIDPattern.MatchString(sessionID) {
+		writeBadRequest(w, r, "Invalid session id")
+		return persons.Player{}, false
+	}
+
+	if sessionID == "" && create {
+		var err error
+		if sessionID, err = newSessionID(); err != nil {
+			writeFailure(w, r, err, "Failed to create session id")
+			return persons.Player{}, false
+		}
+	}
+
+	if sessionID != "" {
+		w.Header().Set(SessionIDHeader, sessionID)
+		middleware.SetPlayer(r, "session:"+fingerprint(sessionID))
+	}
+
+	return persons.Player{SessionID: sessionID}, true
+}
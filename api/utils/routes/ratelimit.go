@@ -0,0 +1,105 @@
+package routes
+
+import (
+	persons "api/struct"
+	apisecurity "api/utils/apisecurity"
+	metrics "api/utils/metrics"
+	middleware "api/utils/middleware"
+	ratelimit "api/utils/ratelimit"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// limit is the rate a client IP or a player gets
+type limit struct {
+	key  string
+	id   string
+	rate ratelimit.Rate
+}
+
+// limited answers 429 with a Retry-After header once the client IP or the
+// player ran out of tokens for action. The IP limit catches scripts that
+// drop their session id to get a fresh one on every guess.
+func (s *Server) limited(action string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limits := []limit{
+			{"ip", s.clientIP(r), ratelimit.Rate{Burst: s.Config.IPRateBurst, Interval: time.Duration(s.Config.IPRateInterval)}},
+		}
+		if player := s.playerKey(r); player != "" {
+			limits = append(limits, limit{"player", player, ratelimit.Rate{Burst: s.Config.PlayerRateBurst, Interval: time.Duration(s.Config.PlayerRateInterval)}})
+		}
+
+		for _, limit := range limits {
+			wait, err := ratelimit.Take(r.Context(), s.Buckets, action+":"+limit.key+":"+limit.id, limit.rate)
+			if err != nil {
+				// Losing the limiter must not take the game down with it
+				slog.WarnContext(r.Context(), "Rate limiting unavailable, letting the request through", "request_id", middleware.RequestIDFrom(r.Context()), "error", err)
+				continue
+			}
+			if wait > 0 {
+				seconds := int(math.Ceil(wait.Seconds()))
+				metrics.RateLimited.WithLabelValues(action, limit.key).Inc()
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+				writeError(w, r, http.StatusTooManyRequests, persons.CodeRateLimited, fmt.Sprintf("Too many requests, retry in %d seconds", seconds))
+				return
+			}
+		}
+
+		handler(w, r)
+	}
+}
+
+// clientIP returns the address the request comes from. Behind the ingress
+// it is the last X-Forwarded-For hop, the one appended by the ingress
+// itself; the earlier ones are whatever the client sent. IPv6 clients are
+// limited per /64, the block a single host usually gets.
+func (s *Server) clientIP(r *http.Request) string {
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	if s.Config.TrustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if hop := strings.TrimSpace(hops[len(hops)-1]); hop != "" {
+				address = hop
+			}
+		}
+	}
+
+	ip, err := netip.ParseAddr(address)
+	if err != nil {
+		return address
+	}
+	ip = ip.Unmap()
+	if ip.Is6() {
+		prefix, _ := ip.Prefix(64)
+		return prefix.String()
+	}
+	return ip.String()
+}
+
+// playerKey identifies the player of the request. Registered players are
+// limited by user, whatever session they log in with; anonymous session ids
+// are fingerprinted so they are never stored. A session token that does not
+// resolve gets no player limit, the handler rejects it anyway.
+func (s *Server) playerKey(r *http.Request) string {
+	if token := apisecurity.SessionToken(r); token != "" {
+		user, err := s.Store.GetUserBySession(r.Context(), token)
+		if err != nil {
+			return ""
+		}
+		return "user:" + user.ID.Hex()
+	}
+	if sessionID := r.Header.Get(SessionIDHeader); sessionIDPattern.MatchString(sessionID) {
+		return "session:" + fingerprint(sessionID)
+	}
+	return ""
+}
@@ -1,221 +1,320 @@
 package routes
 
 import (
-	db "api/db"
 	persons "api/struct"
-	apisecurity "api/utils/apisecurity"
-	ctxUtil "api/utils/context"
+	game "api/utils/game"
+	metrics "api/utils/metrics"
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"time"
+)
+
+const (
+	defaultScheduleDays = 30
+	maxScheduleDays     = 366
 
-	"go.mongodb.org/mongo-driver/mongo"
+	// readyTimeout bounds the whole readiness check
+	readyTimeout = 2 * time.Second
 )
 
 // Route : /health
-func HealthHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	if _, err := fmt.Fprintf(w, "Healthy"); err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		slog.Error("Failed to write response", "error", err)
 	}
 }
 
-// Route : /public/v1/persons
-func GetPersons(w http.ResponseWriter, r *http.Request) {
+// Route : /livez
+// The process is up and serving, whatever the state of its dependencies
+func (s *Server) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Route : /readyz
+// The API can serve the game: the store answers and today's person exists.
+// The probe only reads, picking is left to the rotation job, which retries,
+// and to the first guess of the day.
+func (s *Server) Readyz(w http.ResponseWriter, r *http.Request) {
+	// Answer well within the probe timeout of the deployment
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	if err := s.Store.Ping(ctx); err != nil {
+		slog.WarnContext(r.Context(), "Readiness check failed", "check", "store", "error", err)
+		writeError(w, r, http.StatusServiceUnavailable, persons.CodeUnavailable, "Not ready: the store is unreachable")
+		return
+	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+	if _, err := s.Store.GetPersonOfTheDay(ctx, s.Clock.Today()); err != nil {
+		slog.WarnContext(r.Context(), "Readiness check failed", "check", "person_of_the_day", "error", err)
+		writeError(w, r, http.StatusServiceUnavailable, persons.CodeUnavailable, "Not ready: no person of the day for "+s.Clock.Today())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// Route : /public/v1/persons
+func (s *Server) GetPersons(w http.ResponseWriter, r *http.Request) {
 
 	// Get persons from the database
-	personsList, err := db.GetPersons(mongoClient, "dodle")
+	personsList, err := s.Store.GetPersons(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to get persons: "+err.Error(), http.StatusInternalServerError)
+		writeFailure(w, r, err, "Failed to get persons")
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-
-	// Encode persons as JSON and send response
-	if err := json.NewEncoder(w).Encode(personsList); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	// Only expose what is needed to pick a person
+	publicPersons := persons.PublicPersons{Persons: []persons.PublicPerson{}}
+	for _, person := range personsList.Persons {
+		publicPersons.Persons = append(publicPersons.Persons, person.Public())
 	}
+
+	writeJSON(w, http.StatusOK, publicPersons)
 }
 
 // Route : /private/v1/guess/persons
-func GetPersonsOfTheDay(w http.ResponseWriter, r *http.Request) {
+func (s *Server) GetPersonsOfTheDay(w http.ResponseWriter, r *http.Request) {
 
-	// Check if the request is authorized with API token
-	if !apisecurity.IsAuthorized(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeReadAnswers) {
 		return
 	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
-
 	// Update person of the day
-	personsGuess, err := db.GetPersonsOfTheDay(mongoClient, "dodle")
+	personsGuess, err := s.Store.GetPersonsOfTheDay(r.Context())
 	if err != nil {
-		http.Error(w, "Failed to update person of the day: "+err.Error(), http.StatusInternalServerError)
+		writeFailure(w, r, err, "Failed to update person of the day")
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(personsGuess); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
+	writeJSON(w, http.StatusOK, personsGuess)
 }
 
 // Route : /private/v1/guess/person/create
-func CreatePersonOfTheDay(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreatePersonOfTheDay(w http.ResponseWriter, r *http.Request) {
 
-	// Check if the request is authorized with API token
-	if !apisecurity.IsAuthorized(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeRotatePerson) {
 		return
 	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
-
 	// Update person of the day
-	if err := db.UpdatePersonOfTheDay(mongoClient); err != nil {
-		http.Error(w, "Failed to update person of the day: "+err.Error(), http.StatusInternalServerError)
+	if err := game.UpdatePersonOfTheDay(r.Context(), s.Store, s.Clock, s.Config.ScheduleSeed); err != nil {
+		writeFailure(w, r, err, "Failed to update person of the day")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if _, err := fmt.Fprintln(w, "Person of the day updated successfully"); err != nil {
-		http.Error(w, "Failed to write response", http.StatusInternalServerError)
+		slog.Error("Failed to write response", "error", err)
 	}
 }
 
-// Route : /public/v1/guess/person/submit
-func GuessPersonOfTheDay(w http.ResponseWriter, r *http.Request) {
+// Route : /private/v1/guess/schedule?days=30
+func (s *Server) GetSchedule(w http.ResponseWriter, r *http.Request) {
+
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeReadAnswers) {
+		return
+	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+	days, err := queryInt(r, "days", defaultScheduleDays)
+	if err != nil {
+		writeBadRequest(w, r, err.Error())
+		return
+	}
+	if days > maxScheduleDays {
+		days = maxScheduleDays
+	}
+
+	picks, err := game.GetSchedule(r.Context(), s.Store, s.Clock, s.Config.ScheduleSeed, days)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get schedule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, picks)
+}
+
+// Route : /public/v1/guess/person/submit
+func (s *Server) GuessPersonOfTheDay(w http.ResponseWriter, r *http.Request) {
 
 	// Decode the guess from the request body
-	var guess persons.Person
+	var guess persons.GuessRequest
 	if err := json.NewDecoder(r.Body).Decode(&guess); err != nil {
-		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		writeBadRequest(w, r, "Invalid request body: "+err.Error())
 		return
 	}
 
-	fmt.Println("Received guess:", guess)
+	player, ok := s.resolvePlayer(w, r, true)
+	if !ok {
+		return
+	}
 
 	// Try to guess the person of the day
-	correct, returnedPerson, err := db.TryGuess(mongoClient, "dodle", guess)
+	result, err := game.TryGuess(r.Context(), s.Store, s.Clock, s.Config.ScheduleSeed, guess)
 	if err != nil {
-		http.Error(w, "Failed to process guess: "+err.Error(), http.StatusInternalServerError)
+		writeFailure(w, r, err, "Failed to process guess")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(map[string]interface{}{
-		"correct": correct,
-		"person":  returnedPerson,
-	}); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	if err := s.Store.SaveGuess(r.Context(), player, result); err != nil {
+		writeFailure(w, r, err, "Failed to save guess")
 		return
 	}
-}
 
-// Route : /public/v1/guess/person/hint
-func GetHint(w http.ResponseWriter, r *http.Request) {
+	if result.Correct {
+		metrics.Guesses.WithLabelValues(metrics.ResultCorrect).Inc()
+		s.observeSolve(r, player)
+	} else {
+		metrics.Guesses.WithLabelValues(metrics.ResultIncorrect).Inc()
+	}
+
+	// Only registered players appear on the leaderboards
+	if result.Correct && player.UserID != nil {
+		if err := s.Store.RecordScore(r.Context(), *player.UserID); err != nil {
+			writeFailure(w, r, err, "Failed to record score")
+			return
+		}
+	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"correct":    result.Correct,
+		"guessed":    result.Guessed,
+		"person":     result.Person,
+		"hints":      result.Hints,
+		"session_id": player.SessionID,
+	})
+}
 
-	// Get person of the day
-	personOfTheDay, err := db.GetPersonOfTheDay(mongoClient, "dodle")
+// observeSolve records how many guesses the player needed, the first time
+// they find today's person
+func (s *Server) observeSolve(r *http.Request, player persons.Player) {
+	guesses, err := s.Store.GetGuessHistory(r.Context(), player)
 	if err != nil {
-		http.Error(w, "Failed to get person of the day: "+err.Error(), http.StatusInternalServerError)
+		slog.WarnContext(r.Context(), "Failed to measure attempts to solve", "error", err)
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
+	for i, guess := range guesses {
+		if guess.Correct {
+			// Only the guess just saved may be correct on a first solve
+			if i == len(guesses)-1 {
+				metrics.AttemptsToSolve.Observe(float64(len(guesses)))
+			}
+			return
+		}
+	}
+}
 
-	if err := json.NewEncoder(w).Encode(personOfTheDay.Hint); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+// Route : /public/v1/guess/history
+func (s *Server) GetGuessHistory(w http.ResponseWriter, r *http.Request) {
+
+	player, ok := s.resolvePlayer(w, r, false)
+	if !ok {
 		return
 	}
+
+	// Nothing to look up for a player who never guessed
+	guesses := []persons.Guess{}
+	if player.UserID != nil || player.SessionID != "" {
+		var err error
+		if guesses, err = s.Store.GetGuessHistory(r.Context(), player); err != nil {
+			writeFailure(w, r, err, "Failed to get guess history")
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"date":    s.Clock.Today(),
+		"guesses": guesses,
+	})
 }
 
-// Route : /private/v1/guess/persons/today
-func GetPersonOfTheDay(w http.ResponseWriter, r *http.Request) {
+// Route : /public/v1/guess/person/hint
+func (s *Server) GetHint(w http.ResponseWriter, r *http.Request) {
 
-	// Check if the request is authorized with API token
-	if !apisecurity.IsAuthorized(r) {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	// The hint is only unlocked after enough wrong guesses today
+	player, ok := s.resolvePlayer(w, r, false)
+	if !ok {
 		return
 	}
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+	failedGuesses := int64(0)
+	if player.UserID != nil || player.SessionID != "" {
+		var err error
+		if failedGuesses, err = s.Store.CountFailedGuesses(r.Context(), player); err != nil {
+			writeFailure(w, r, err, "Failed to count guesses")
+			return
+		}
+	}
 
-	// Update person of the day
-	personsGuess, err := db.GetPersonsOfTheDay(mongoClient, "dodle")
-	if err != nil {
-		http.Error(w, "Failed to update person of the day: "+err.Error(), http.StatusInternalServerError)
+	if required := s.Config.HintAfterGuesses; failedGuesses < required {
+		metrics.HintRequests.WithLabelValues(metrics.ResultLocked).Inc()
+		writeError(w, r, http.StatusForbidden, persons.CodeHintLocked, fmt.Sprintf("Hint unlocks after %d wrong guesses, %d to go", required, required-failedGuesses))
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(personsGuess[len(personsGuess)-1]); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	// Get person of the day
+	personOfTheDay, err := game.EnsurePersonOfTheDay(r.Context(), s.Store, s.Clock, s.Config.ScheduleSeed)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get person of the day")
 		return
 	}
+
+	metrics.HintRequests.WithLabelValues(metrics.ResultRevealed).Inc()
+	writeJSON(w, http.StatusOK, map[string]string{"hint": personOfTheDay.Hint})
 }
 
-// Route : /public/v1/persons/yesterday
-func GetPersonOfYesterday(w http.ResponseWriter, r *http.Request) {
+// Route : /private/v1/guess/person/today
+func (s *Server) GetPersonOfTheDay(w http.ResponseWriter, r *http.Request) {
 
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+	// Check if the request is authorized with an API key
+	if !s.requireScope(w, r, persons.ScopeReadAnswers) {
+		return
+	}
 
-	// Get person of yesterday
-	personOfYesterday, err := db.GetPersonOfYesterday(mongoClient, "dodle")
+	// Get person of the day
+	personOfTheDay, err := s.Store.GetPersonOfTheDay(r.Context(), s.Clock.Today())
 	if err != nil {
-		http.Error(w, "Failed to get person of yesterday: "+err.Error(), http.StatusInternalServerError)
+		writeFailure(w, r, err, "Failed to get person of the day")
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
+	writeJSON(w, http.StatusOK, personOfTheDay)
+}
 
-	if err := json.NewEncoder(w).Encode(personOfYesterday); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+// Route : /public/v1/persons/yesterday
+func (s *Server) GetPersonOfYesterday(w http.ResponseWriter, r *http.Request) {
+
+	// Get person of yesterday
+	personOfYesterday, err := game.GetPersonOfYesterday(r.Context(), s.Store, s.Clock)
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get person of yesterday")
 		return
 	}
+
+	writeJSON(w, http.StatusOK, personOfYesterday)
 }
 
 // Route : /public/v1/guess/id
-func GetGuessID(w http.ResponseWriter, r *http.Request) {
-
-	// Get MongoDB client from context
-	mongoClient := r.Context().Value(ctxUtil.MongoClientKey).(*mongo.Client)
+func (s *Server) GetGuessID(w http.ResponseWriter, r *http.Request) {
 
-	id, error := db.GetGuessID(mongoClient, "dodle")
-
-	if id == "" || error != nil {
-		http.Error(w, "No guess found for today", http.StatusNotFound)
+	// Make sure today's game exists so clients do not get yesterday's id
+	if _, err := game.EnsurePersonOfTheDay(r.Context(), s.Store, s.Clock, s.Config.ScheduleSeed); err != nil {
+		writeFailure(w, r, err, "Failed to get person of the day")
 		return
 	}
 
-	// Set response headers
-	w.Header().Set("Content-Type", "application/json")
-
-	if err := json.NewEncoder(w).Encode(map[string]string{"id": id}); err != nil {
-		http.Error(w, "Failed to encode response: "+err.Error(), http.StatusInternalServerError)
+	id, err := s.Store.GetGuessID(r.Context(), s.Clock.Today())
+	if err != nil {
+		writeFailure(w, r, err, "Failed to get guess id")
 		return
 	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
 }
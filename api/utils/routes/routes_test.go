@@ -0,0 +1,976 @@
+package routes_test
+
+import (
+	memory "api/db/memory"
+	persons "api/struct"
+	config "api/utils/config"
+	gameclock "api/utils/gameclock"
+	middleware "api/utils/middleware"
+	routes "api/utils/routes"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const legacyToken = "test-token"
+
+var ctx = context.Background()
+
+var catalogue = []persons.Person{
+	{Firstname: "Ada", Lastname: "Lovelace", Gender: "Femme", Type: "DO24-27", Workplace: "CIRAD", Hint: "Analytical engine"},
+	{Firstname: "Alan", Lastname: "Turing", Gender: "Homme", Type: "DO23-26", Workplace: "INRAE", Hint: "Enigma"},
+	{Firstname: "Grace", Lastname: "Hopper", Gender: "Femme", Type: "DO25-28", Workplace: "CIRAD", Hint: "COBOL"},
+}
+
+// request describes one call to the API
+type request struct {
+	method  string
+	path    string
+	body    string
+	headers map[string]string
+}
+
+// fixture is a server backed by a fresh in-memory store, on a game day
+// pinned to 2025-06-30
+type fixture struct {
+	t      *testing.T
+	clock  *gameclock.Clock
+	store  *memory.Store
+	server http.Handler
+}
+
+func newFixture(t *testing.T, configure ...func(cfg *config.Config)) *fixture {
+	t.Helper()
+
+	now := time.Date(2025, 6, 30, 12, 0, 0, 0, time.UTC)
+	clock := &gameclock.Clock{Location: time.UTC, RolloverHour: 10, Now: func() time.Time { return now }}
+
+	cfg := config.Default()
+	cfg.APIToken = legacyToken
+	cfg.ScheduleSeed = "test-seed"
+	cfg.HintAfterGuesses = 1
+	for _, apply := range configure {
+		apply(&cfg)
+	}
+
+	store := memory.New(clock, catalogue...)
+	return &fixture{t: t, clock: clock, store: store, server: routes.NewServer(store, cfg, clock).Routes()}
+}
+
+// pick stores the person with the given id as the person of the day offset
+// days from today
+func (f *fixture) pick(offset int, id string) {
+	f.t.Helper()
+	person, err := f.store.FindPerson(ctx, id)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	if err := f.store.CreatePersonOfTheDay(ctx, f.clock.Day(offset), person); err != nil {
+		f.t.Fatal(err)
+	}
+}
+
+func (f *fixture) do(req request) *httptest.ResponseRecorder {
+	f.t.Helper()
+	if req.method == "" {
+		req.method = http.MethodGet
+	}
+	r := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+	for name, value := range req.headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	f.server.ServeHTTP(w, r)
+	return w
+}
+
+// apiKey mints a key with the given scopes through the API
+func (f *fixture) apiKey(scopes ...string) string {
+	f.t.Helper()
+	body, _ := json.Marshal(persons.APIKeyRequest{Name: "test", Scopes: scopes})
+	w := f.do(request{method: http.MethodPost, path: "/private/v1/keys", body: string(body), headers: map[string]string{"API-Token": legacyToken}})
+	if w.Code != http.StatusCreated {
+		f.t.Fatalf("minting an api key answered %d: %s", w.Code, w.Body)
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	decode(f.t, w, &created)
+	return created.Key
+}
+
+// admin returns the headers of a key holding every scope, as an
+// administrator minted from the legacy token would use
+func (f *fixture) admin() map[string]string {
+	f.t.Helper()
+	return map[string]string{"API-Token": f.apiKey(persons.Scopes...)}
+}
+
+// login registers a player and returns the Authorization header of its session
+func (f *fixture) login(username string) map[string]string {
+	f.t.Helper()
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/auth/register", body: `{"username":"` + username + `","password":"correct horse"}`})
+	if w.Code != http.StatusCreated {
+		f.t.Fatalf("register answered %d: %s", w.Code, w.Body)
+	}
+	var session struct {
+		Token string `json:"token"`
+	}
+	decode(f.t, w, &session)
+	return map[string]string{"Authorization": "Bearer " + session.Token}
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, value interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), value); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", w.Body.String(), err)
+	}
+}
+
+// expectError checks the status and the code of a JSON error envelope
+func expectError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var response persons.ErrorResponse
+	decode(t, w, &response)
+	if response.Code != code {
+		t.Errorf("error code = %q, want %q", response.Code, code)
+	}
+	if response.Message == "" {
+		t.Error("error message is empty")
+	}
+	if got := w.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+		t.Errorf("X-Content-Type-Options = %q, want nosniff", got)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	w := f.do(request{path: "/health"})
+	if w.Code != http.StatusOK || w.Body.String() != "Healthy" {
+		t.Errorf("/health = %d %q", w.Code, w.Body)
+	}
+}
+
+// downStore is a store whose database cannot be reached
+type downStore struct {
+	*memory.Store
+}
+
+func (downStore) Ping(ctx context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestProbes(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	down := routes.NewServer(downStore{f.store}, config.Default(), f.clock).Routes()
+
+	expectStatus := func(t *testing.T, server http.Handler, path string, status int) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != status {
+			t.Errorf("%s = %d, want %d: %s", path, w.Code, status, w.Body)
+		}
+	}
+
+	// Unready until today's person is picked, which the probe never does
+	expectStatus(t, f.server, "/livez", http.StatusOK)
+	expectStatus(t, f.server, "/readyz", http.StatusServiceUnavailable)
+	if _, err := f.store.GetPersonOfTheDay(ctx, f.clock.Today()); err == nil {
+		t.Error("readyz picked today's person")
+	}
+	f.pick(0, "ada-lovelace")
+	expectStatus(t, f.server, "/readyz", http.StatusOK)
+
+	// Liveness does not depend on the database
+	expectStatus(t, down, "/livez", http.StatusOK)
+	expectStatus(t, down, "/readyz", http.StatusServiceUnavailable)
+}
+
+// slowStore is a store whose database never answers in time
+type slowStore struct {
+	*memory.Store
+}
+
+func (slowStore) GetPersons(ctx context.Context) (persons.Persons, error) {
+	return persons.Persons{}, fmt.Errorf("failed to find persons: %w", context.DeadlineExceeded)
+}
+
+func TestDatabaseTimeout(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	slow := routes.NewServer(slowStore{f.store}, config.Default(), f.clock).Routes()
+
+	w := httptest.NewRecorder()
+	slow.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/v1/persons", nil))
+	expectError(t, w, http.StatusServiceUnavailable, persons.CodeUnavailable)
+}
+
+func TestUnknownRoute(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	expectError(t, f.do(request{path: "/public/v1/nope"}), http.StatusNotFound, persons.CodeNotFound)
+}
+
+func TestGetPersons(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	if err := f.store.SoftDeletePerson(ctx, "grace-hopper"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := f.do(request{path: "/public/v1/persons"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "Enigma") {
+		t.Error("public persons leak hints")
+	}
+	var list persons.PublicPersons
+	decode(t, w, &list)
+	if len(list.Persons) != 2 {
+		t.Errorf("got %d persons, want the 2 not deleted", len(list.Persons))
+	}
+	for _, person := range list.Persons {
+		if person.ID == "grace-hopper" {
+			t.Error("deleted person is listed")
+		}
+	}
+}
+
+func TestPrivateRoutesAuthorization(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(0, "ada-lovelace")
+	readAnswers := f.apiKey(persons.ScopeReadAnswers)
+	rotate := f.apiKey(persons.ScopeRotatePerson)
+
+	// Flip the last secret character to keep a well formed key
+	tampered := readAnswers[:len(readAnswers)-1] + "x"
+	if strings.HasSuffix(readAnswers, "x") {
+		tampered = readAnswers[:len(readAnswers)-1] + "y"
+	}
+
+	routes := []string{
+		"/private/v1/guess/persons",
+		"/private/v1/guess/person/today",
+		"/private/v1/guess/schedule",
+	}
+	tests := []struct {
+		name   string
+		token  string
+		status int
+		code   string
+	}{
+		{"no key", "", http.StatusUnauthorized, persons.CodeUnauthorized},
+		{"malformed key", "not-a-key", http.StatusUnauthorized, persons.CodeUnauthorized},
+		{"unknown key", "dodle_abcdef_0123456789", http.StatusUnauthorized, persons.CodeUnauthorized},
+		{"wrong secret", tampered, http.StatusUnauthorized, persons.CodeUnauthorized},
+		{"missing scope", rotate, http.StatusForbidden, persons.CodeForbidden},
+		{"scoped key", readAnswers, http.StatusOK, ""},
+		{"legacy token", legacyToken, http.StatusForbidden, persons.CodeForbidden},
+	}
+	for _, path := range routes {
+		for _, tt := range tests {
+			t.Run(path+"/"+tt.name, func(t *testing.T) {
+				w := f.do(request{path: path, headers: map[string]string{"API-Token": tt.token}})
+				if tt.code == "" {
+					if w.Code != tt.status {
+						t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+					}
+					return
+				}
+				expectError(t, w, tt.status, tt.code)
+			})
+		}
+	}
+}
+
+func TestRevokedKeyIsRejected(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	key := f.apiKey(persons.ScopeReadAnswers)
+	keys, err := f.store.ListAPIKeys(ctx)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("ListAPIKeys() = %v, %v", keys, err)
+	}
+
+	w := f.do(request{method: http.MethodDelete, path: "/private/v1/keys/" + keys[0].ID.Hex(), headers: map[string]string{"API-Token": legacyToken}})
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("revoke answered %d: %s", w.Code, w.Body)
+	}
+
+	w = f.do(request{path: "/private/v1/guess/persons", headers: map[string]string{"API-Token": key}})
+	expectError(t, w, http.StatusUnauthorized, persons.CodeUnauthorized)
+}
+
+func TestCreatePersonOfTheDay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		method     string
+		deprecated bool
+	}{
+		{"post", http.MethodPost, false},
+		{"deprecated get", http.MethodGet, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			w := f.do(request{method: tt.method, path: "/private/v1/guess/person/create", headers: map[string]string{"API-Token": f.apiKey(persons.ScopeRotatePerson)}})
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body)
+			}
+			if _, err := f.store.GetPersonOfTheDay(ctx, f.clock.Today()); err != nil {
+				t.Errorf("no person of the day after rotation: %v", err)
+			}
+			if got := w.Header().Get("Deprecation") != ""; got != tt.deprecated {
+				t.Errorf("Deprecation header set = %t, want %t", got, tt.deprecated)
+			}
+		})
+	}
+}
+
+func TestAdminPersons(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	admin := f.admin()
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/private/v1/persons/ada-lovelace", http.StatusOK},
+		{http.MethodGet, "/private/v1/persons/nobody", http.StatusNotFound},
+		{http.MethodDelete, "/private/v1/persons/ada-lovelace", http.StatusNoContent},
+		{http.MethodGet, "/private/v1/persons", http.StatusOK},
+		{http.MethodPost, "/private/v1/persons/ada-lovelace/restore", http.StatusNoContent},
+		{http.MethodGet, "/public/v1/persons/ada-lovelace/image", http.StatusNotFound},
+		{http.MethodGet, "/public/v1/persons/ada-lovelace/other", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := f.do(request{method: tt.method, path: tt.path, headers: admin})
+		if w.Code != tt.status {
+			t.Errorf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.status, w.Body)
+		}
+	}
+}
+
+func TestUpdatePersonKeepsPortrait(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	admin := f.admin()
+	if _, err := f.store.SavePortrait(ctx, "ada-lovelace", map[string][]byte{"large": []byte("portrait")}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{
+		`{"firstname":"Ada","lastname":"Lovelace","gender":"Femme","type":"DO24-27","workplace":"CIRAD","hint":"First program"}`,
+		`{"firstname":"Ada","lastname":"Lovelace","gender":"Femme","type":"DO24-27","workplace":"CIRAD","hint":"First program","image":"https://elsewhere.example/ada.jpg"}`,
+	} {
+		w := f.do(request{method: http.MethodPut, path: "/private/v1/persons/ada-lovelace", body: body, headers: admin})
+		var updated persons.Person
+		decode(t, w, &updated)
+		if w.Code != http.StatusOK || updated.Hint != "First program" {
+			t.Fatalf("update = %d %+v", w.Code, updated)
+		}
+		if stored, _ := f.store.FindPerson(ctx, "ada-lovelace"); stored.Image != persons.PortraitPath("ada-lovelace") {
+			t.Errorf("image after update = %q, want the uploaded portrait", stored.Image)
+		}
+	}
+}
+
+func TestGetPersonImage(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	path := "/public/v1/persons/ada-lovelace/image?size=small"
+
+	upload := func(content string) string {
+		t.Helper()
+		if _, err := f.store.SavePortrait(ctx, "ada-lovelace", map[string][]byte{"small": []byte(content)}); err != nil {
+			t.Fatal(err)
+		}
+		w := f.do(request{path: path})
+		if w.Code != http.StatusOK || w.Body.String() != content {
+			t.Fatalf("GET image = %d %q, want 200 %q", w.Code, w.Body, content)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "public, max-age=300, must-revalidate" {
+			t.Errorf("Cache-Control = %q", got)
+		}
+		return w.Header().Get("ETag")
+	}
+
+	etag := upload("first portrait")
+	if etag != persons.PortraitETag([]byte("first portrait")) {
+		t.Errorf("ETag = %s, want the content hash", etag)
+	}
+	if w := f.do(request{path: path, headers: map[string]string{"If-None-Match": etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("revalidating the same portrait = %d, want 304", w.Code)
+	}
+
+	// A new upload keeps the URL but changes the ETag, so caches refetch it
+	if again := upload("second portrait"); again == etag {
+		t.Errorf("ETag %s did not change with the portrait", again)
+	}
+	if w := f.do(request{path: path, headers: map[string]string{"If-None-Match": etag}}); w.Code != http.StatusOK {
+		t.Errorf("revalidating a replaced portrait = %d, want 200", w.Code)
+	}
+}
+
+// unreadablePortraits is a store that fails whenever a portrait's content is
+// read, to check revalidations only look at the stored ETag
+type unreadablePortraits struct {
+	*memory.Store
+}
+
+func (unreadablePortraits) GetPortrait(ctx context.Context, personID string, size string) (persons.Portrait, error) {
+	return persons.Portrait{}, errors.New("portrait content read")
+}
+
+func TestRevalidatePortraitWithoutReadingIt(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	if _, err := f.store.SavePortrait(ctx, "ada-lovelace", map[string][]byte{"large": []byte("portrait")}); err != nil {
+		t.Fatal(err)
+	}
+	server := routes.NewServer(unreadablePortraits{f.store}, config.Default(), f.clock).Routes()
+
+	for _, header := range []string{persons.PortraitETag([]byte("portrait")), `"other", W/` + persons.PortraitETag([]byte("portrait"))} {
+		r := httptest.NewRequest(http.MethodGet, "/public/v1/persons/ada-lovelace/image", nil)
+		r.Header.Set("If-None-Match", header)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s = %d, want 304: %s", header, w.Code, w.Body)
+		}
+	}
+}
+
+func TestUploadPortraitTooLarge(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	admin := f.admin()
+	oversized := strings.Repeat("x", 6<<20)
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("image", "portrait.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write([]byte(oversized)); err != nil {
+		t.Fatal(err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, req := range map[string]request{
+		"multipart": {body: body.String(), headers: map[string]string{"API-Token": admin["API-Token"], "Content-Type": form.FormDataContentType()}},
+		"raw body":  {body: oversized, headers: admin},
+	} {
+		req.method, req.path = http.MethodPost, "/private/v1/persons/ada-lovelace/image"
+		t.Run(name, func(t *testing.T) {
+			expectError(t, f.do(req), http.StatusRequestEntityTooLarge, persons.CodeTooLarge)
+		})
+	}
+}
+
+func TestNoPersonOfTheDay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		req   request
+		admin bool
+	}{
+		{"yesterday", request{path: "/public/v1/guess/person/yesterday"}, false},
+		{"private today", request{path: "/private/v1/guess/person/today"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.admin {
+				tt.req.headers = f.admin()
+			}
+			expectError(t, f.do(tt.req), http.StatusNotFound, persons.CodeNoPersonOfTheDay)
+		})
+	}
+}
+
+func TestGetPersonOfYesterday(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(-1, "alan-turing")
+	f.pick(0, "ada-lovelace")
+
+	w := f.do(request{path: "/public/v1/guess/person/yesterday"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var person persons.Person
+	decode(t, w, &person)
+	if person.ID != "alan-turing" {
+		t.Errorf("yesterday's person = %q, want alan-turing", person.ID)
+	}
+}
+
+func TestGuessPersonOfTheDay(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		status  int
+		code    string
+		correct bool
+	}{
+		{name: "correct guess", body: `{"id":"ada-lovelace"}`, status: http.StatusOK, correct: true},
+		{name: "legacy names", body: `{"firstname":"Ada","lastname":"Lovelace"}`, status: http.StatusOK, correct: true},
+		{name: "incorrect guess", body: `{"id":"grace-hopper"}`, status: http.StatusOK},
+		{name: "unknown person", body: `{"id":"nobody"}`, status: http.StatusBadRequest, code: persons.CodeUnknownPerson},
+		{name: "deleted person", body: `{"id":"alan-turing"}`, status: http.StatusBadRequest, code: persons.CodeUnknownPerson},
+		{name: "invalid body", body: `{"id":`, status: http.StatusBadRequest, code: persons.CodeBadRequest},
+		{name: "invalid session id", body: `{"id":"ada-lovelace"}`, headers: map[string]string{"X-Session-ID": "nope"}, status: http.StatusBadRequest, code: persons.CodeBadRequest},
+		{name: "unknown session token", body: `{"id":"ada-lovelace"}`, headers: map[string]string{"Authorization": "Bearer nope"}, status: http.StatusUnauthorized, code: persons.CodeInvalidSession},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			f.pick(0, "ada-lovelace")
+			if err := f.store.SoftDeletePerson(ctx, "alan-turing"); err != nil {
+				t.Fatal(err)
+			}
+
+			w := f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: tt.body, headers: tt.headers})
+			if tt.code != "" {
+				expectError(t, w, tt.status, tt.code)
+				return
+			}
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			var result struct {
+				Correct   bool           `json:"correct"`
+				Person    persons.Person `json:"person"`
+				Hints     persons.Hints  `json:"hints"`
+				SessionID string         `json:"session_id"`
+			}
+			decode(t, w, &result)
+			if result.Correct != tt.correct {
+				t.Errorf("correct = %t, want %t", result.Correct, tt.correct)
+			}
+			if result.SessionID == "" || w.Header().Get("X-Session-ID") != result.SessionID {
+				t.Errorf("session id %q not issued in the header", result.SessionID)
+			}
+			if strings.Contains(w.Body.String(), "Analytical engine") {
+				t.Error("guess response leaks the hint")
+			}
+			if !tt.correct && result.Person.Firstname != "" {
+				t.Errorf("incorrect guess reveals the firstname %q", result.Person.Firstname)
+			}
+			if !tt.correct && result.Hints.Gender != persons.VerdictCorrect {
+				t.Errorf("gender verdict = %q, want %q", result.Hints.Gender, persons.VerdictCorrect)
+			}
+		})
+	}
+}
+
+func TestGuessRotatesWhenTodayHasNoPerson(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"ada-lovelace"}`})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	if _, err := f.store.GetPersonOfTheDay(ctx, f.clock.Today()); err != nil {
+		t.Errorf("guessing did not rotate the person of the day: %v", err)
+	}
+}
+
+func TestGuessHistoryAndHint(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(0, "ada-lovelace")
+
+	// No session yet, so no wrong guess either
+	expectError(t, f.do(request{path: "/public/v1/guess/person/hint"}), http.StatusForbidden, persons.CodeHintLocked)
+
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"grace-hopper"}`})
+	session := map[string]string{"X-Session-ID": w.Header().Get("X-Session-ID")}
+
+	w = f.do(request{path: "/public/v1/guess/person/hint", headers: session})
+	if w.Code != http.StatusOK {
+		t.Fatalf("hint status = %d: %s", w.Code, w.Body)
+	}
+	var hint map[string]string
+	decode(t, w, &hint)
+	if hint["hint"] != "Analytical engine" {
+		t.Errorf("hint = %q", hint["hint"])
+	}
+
+	w = f.do(request{path: "/public/v1/guess/history", headers: session})
+	var history struct {
+		Date    string          `json:"date"`
+		Guesses []persons.Guess `json:"guesses"`
+	}
+	decode(t, w, &history)
+	if history.Date != "2025-06-30" || len(history.Guesses) != 1 {
+		t.Errorf("history = %+v, want one guess on 2025-06-30", history)
+	}
+}
+
+func TestGetGuessID(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	w := f.do(request{path: "/public/v1/guess/id"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var id map[string]string
+	decode(t, w, &id)
+	if id["id"] == "" {
+		t.Error("empty guess id")
+	}
+}
+
+func TestTodayIgnoresPickOrder(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	admin := f.admin()
+
+	// Today's pick is stored before older ones, as a backfill would
+	f.pick(0, "ada-lovelace")
+	before := f.do(request{path: "/public/v1/guess/id"})
+	f.pick(-2, "grace-hopper")
+	f.pick(-1, "alan-turing")
+
+	w := f.do(request{path: "/private/v1/guess/person/today", headers: admin})
+	var today persons.Person
+	decode(t, w, &today)
+	if w.Code != http.StatusOK || today.ID != "ada-lovelace" {
+		t.Errorf("today's person = %d %s, want ada-lovelace", w.Code, today.ID)
+	}
+
+	if after := f.do(request{path: "/public/v1/guess/id"}); after.Body.String() != before.Body.String() {
+		t.Errorf("guess id changed from %s to %s when older picks were stored", before.Body, after.Body)
+	}
+
+	w = f.do(request{path: "/private/v1/guess/persons", headers: admin})
+	var all []persons.Person
+	decode(t, w, &all)
+	var ids []string
+	for _, person := range all {
+		ids = append(ids, person.ID)
+	}
+	if fmt.Sprint(ids) != "[grace-hopper alan-turing ada-lovelace]" {
+		t.Errorf("persons of the day = %v, want oldest first", ids)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.login("ada")
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"login", "/public/v1/auth/login", `{"username":"ada","password":"correct horse"}`, http.StatusOK, ""},
+		{"wrong password", "/public/v1/auth/login", `{"username":"ada","password":"wrong horse"}`, http.StatusUnauthorized, persons.CodeInvalidCredentials},
+		{"unknown user", "/public/v1/auth/login", `{"username":"bob","password":"correct horse"}`, http.StatusUnauthorized, persons.CodeInvalidCredentials},
+		{"username taken", "/public/v1/auth/register", `{"username":"ada","password":"correct horse"}`, http.StatusConflict, persons.CodeUsernameTaken},
+		{"short password", "/public/v1/auth/register", `{"username":"bob","password":"short"}`, http.StatusBadRequest, persons.CodeBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := f.do(request{method: http.MethodPost, path: tt.path, body: tt.body})
+			if tt.code != "" {
+				expectError(t, w, tt.status, tt.code)
+				return
+			}
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
+
+func TestLeaderboard(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(0, "ada-lovelace")
+	ada := f.login("ada")
+
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"ada-lovelace"}`, headers: ada})
+	if w.Code != http.StatusOK {
+		t.Fatalf("guess status = %d: %s", w.Code, w.Body)
+	}
+
+	w = f.do(request{path: "/public/v1/leaderboard?period=day"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("leaderboard status = %d: %s", w.Code, w.Body)
+	}
+	var board persons.Leaderboard
+	decode(t, w, &board)
+	if len(board.Entries) != 1 || board.Entries[0].Username != "ada" || board.Entries[0].Rank != 1 {
+		t.Errorf("leaderboard entries = %+v, want ada first", board.Entries)
+	}
+
+	expectError(t, f.do(request{path: "/public/v1/leaderboard?period=century"}), http.StatusBadRequest, persons.CodeBadRequest)
+	expectError(t, f.do(request{path: "/public/v1/leaderboard?page=9223372036854775807"}), http.StatusBadRequest, persons.CodeBadRequest)
+
+	// The last page whose offset fits is simply empty
+	w = f.do(request{path: fmt.Sprintf("/public/v1/leaderboard?page=%d&page_size=100", math.MaxInt/100)})
+	decode(t, w, &board)
+	if w.Code != http.StatusOK || len(board.Entries) != 0 {
+		t.Errorf("last page = %d with %d entries, want 200 and none", w.Code, len(board.Entries))
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	admin := f.admin()
+	tests := []struct {
+		method string
+		path   string
+		allow  string
+	}{
+		{http.MethodGet, "/public/v1/auth/login", "OPTIONS, POST"},
+		{http.MethodGet, "/public/v1/auth/register", "OPTIONS, POST"},
+		{http.MethodGet, "/public/v1/guess/person/submit", "OPTIONS, POST"},
+		{http.MethodPut, "/private/v1/guess/person/create", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPut, "/private/v1/keys", "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPost, "/private/v1/persons/ada-lovelace", "DELETE, GET, HEAD, OPTIONS, PUT"},
+		{http.MethodGet, "/private/v1/keys/0123456789abcdef01234567", "DELETE, OPTIONS"},
+		{http.MethodPost, "/health", "GET, HEAD, OPTIONS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := f.do(request{method: tt.method, path: tt.path, headers: admin})
+			expectError(t, w, http.StatusMethodNotAllowed, persons.CodeMethodNotAllowed)
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+}
+
+func TestOptions(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	tests := []struct {
+		path  string
+		allow string
+	}{
+		{"/public/v1/guess/person/submit", "OPTIONS, POST"},
+		{"/public/v1/persons", "GET, HEAD, OPTIONS"},
+		{"/private/v1/persons/ada-lovelace", "DELETE, GET, HEAD, OPTIONS, PUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := f.do(request{method: http.MethodOptions, path: tt.path})
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("OPTIONS %s = %d: %s", tt.path, w.Code, w.Body)
+			}
+			if got := w.Header().Get("Allow"); got != tt.allow {
+				t.Errorf("Allow = %q, want %q", got, tt.allow)
+			}
+		})
+	}
+
+	if w := f.do(request{method: http.MethodHead, path: "/health"}); w.Code != http.StatusOK {
+		t.Errorf("HEAD /health = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t, func(cfg *config.Config) {
+		cfg.IPRateBurst = 4
+		cfg.PlayerRateBurst = 2
+		cfg.PlayerRateInterval = config.Duration(3 * time.Second)
+	})
+	f.pick(0, "ada-lovelace")
+
+	session := map[string]string{routes.SessionIDHeader: strings.Repeat("ab", 16)}
+	guess := func(headers map[string]string) *httptest.ResponseRecorder {
+		return f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"alan-turing"}`, headers: headers})
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := guess(session); w.Code != http.StatusOK {
+			t.Fatalf("guess %d = %d: %s", i+1, w.Code, w.Body)
+		}
+	}
+	w := guess(session)
+	expectError(t, w, http.StatusTooManyRequests, persons.CodeRateLimited)
+	if got := w.Header().Get("Retry-After"); got != "3" {
+		t.Errorf("Retry-After = %q, want 3", got)
+	}
+
+	// The hint has its own buckets
+	if hint := f.do(request{path: "/public/v1/guess/person/hint", headers: session}); hint.Code != http.StatusOK {
+		t.Errorf("hint = %d: %s", hint.Code, hint.Body)
+	}
+
+	// Dropping the session id does not escape the limit of the IP, nor does
+	// a forged X-Forwarded-For when the proxy is not trusted
+	if w := guess(nil); w.Code != http.StatusOK {
+		t.Fatalf("guess from a new session = %d: %s", w.Code, w.Body)
+	}
+	expectError(t, guess(map[string]string{"X-Forwarded-For": "203.0.113.7"}), http.StatusTooManyRequests, persons.CodeRateLimited)
+}
+
+func TestRateLimitSurvivesNewLogins(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t, func(cfg *config.Config) {
+		cfg.PlayerRateBurst = 2
+	})
+	f.pick(0, "ada-lovelace")
+	guess := func(headers map[string]string) *httptest.ResponseRecorder {
+		return f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"alan-turing"}`, headers: headers})
+	}
+
+	first := f.login("grace")
+	for i := 0; i < 2; i++ {
+		if w := guess(first); w.Code != http.StatusOK {
+			t.Fatalf("guess %d = %d: %s", i+1, w.Code, w.Body)
+		}
+	}
+
+	// A fresh session of the same player shares the spent bucket
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/auth/login", body: `{"username":"grace","password":"correct horse"}`})
+	var session struct {
+		Token string `json:"token"`
+	}
+	decode(t, w, &session)
+	expectError(t, guess(map[string]string{"Authorization": "Bearer " + session.Token}), http.StatusTooManyRequests, persons.CodeRateLimited)
+}
+
+func TestCORS(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t, func(cfg *config.Config) {
+		cfg.CORSOrigins = []string{"https://dodle.example"}
+		cfg.CORSCredentials = true
+	})
+	preflight := func(origin string) map[string]string {
+		return map[string]string{"Origin": origin, "Access-Control-Request-Method": http.MethodPost}
+	}
+
+	tests := []struct {
+		name        string
+		req         request
+		status      int
+		allowOrigin string
+		allowMethod string
+	}{
+		{"allowed origin", request{path: "/public/v1/persons", headers: map[string]string{"Origin": "https://dodle.example"}}, http.StatusOK, "https://dodle.example", ""},
+		{"other origin", request{path: "/public/v1/persons", headers: map[string]string{"Origin": "https://evil.example"}}, http.StatusOK, "", ""},
+		{"preflight", request{method: http.MethodOptions, path: "/public/v1/guess/person/submit", headers: preflight("https://dodle.example")}, http.StatusNoContent, "https://dodle.example", http.MethodPost},
+		{"preflight from other origin", request{method: http.MethodOptions, path: "/public/v1/guess/person/submit", headers: preflight("https://evil.example")}, http.StatusForbidden, "", ""},
+		{"private preflight", request{method: http.MethodOptions, path: "/private/v1/persons", headers: preflight("https://dodle.example")}, http.StatusForbidden, "", ""},
+		{"private call", request{path: "/private/v1/persons", headers: map[string]string{"Origin": "https://dodle.example", "API-Token": f.admin()["API-Token"]}}, http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := f.do(tt.req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allowOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.allowOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Methods"); got != tt.allowMethod {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.allowMethod)
+			}
+			if tt.status == http.StatusForbidden {
+				expectError(t, w, tt.status, persons.CodeForbidden)
+			}
+			if got := w.Header().Values("Vary"); len(got) == 0 || got[0] != "Origin" {
+				t.Errorf("Vary = %q, want Origin", got)
+			}
+			wantCredentials := ""
+			if tt.allowOrigin != "" {
+				wantCredentials = "true"
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+		})
+	}
+}
+
+func TestAccessLogHidesSessionID(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(0, "ada-lovelace")
+
+	var logs bytes.Buffer
+	logged := middleware.Chain(f.server, middleware.RequestID, middleware.AccessLog(slog.New(slog.NewJSONHandler(&logs, nil))))
+
+	sessionID := strings.Repeat("cd", 16)
+	r := httptest.NewRequest(http.MethodPost, "/public/v1/guess/person/submit", strings.NewReader(`{"id":"alan-turing"}`))
+	r.Header.Set(routes.SessionIDHeader, sessionID)
+	logged.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(logs.String(), `"player_id":"session:`) {
+		t.Fatalf("access log has no player: %s", logs.String())
+	}
+	if strings.Contains(logs.String(), sessionID) {
+		t.Errorf("access log leaks the session id: %s", logs.String())
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+	f := newFixture(t)
+	f.pick(0, "ada-lovelace")
+
+	w := f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"grace-hopper"}`})
+	session := map[string]string{"X-Session-ID": w.Header().Get("X-Session-ID")}
+	f.do(request{path: "/public/v1/guess/person/hint", headers: session})
+	f.do(request{method: http.MethodPost, path: "/public/v1/guess/person/submit", body: `{"id":"ada-lovelace"}`, headers: session})
+	f.do(request{path: "/private/v1/guess/person/create", method: http.MethodPost, headers: f.admin()})
+
+	// Not served on the public listener
+	expectError(t, f.do(request{path: "/metrics"}), http.StatusNotFound, persons.CodeNotFound)
+
+	w = httptest.NewRecorder()
+	routes.MetricsRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	for _, metric := range []string{
+		`dodle_guesses_total{result="correct"}`,
+		`dodle_guesses_total{result="incorrect"}`,
+		`dodle_attempts_to_solve_bucket{le="2"}`,
+		`dodle_hint_requests_total{result="revealed"}`,
+		`dodle_rotations_total{result="success"}`,
+	} {
+		if !strings.Contains(w.Body.String(), metric) {
+			t.Errorf("/metrics lacks %s", metric)
+		}
+	}
+}
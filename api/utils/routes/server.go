@@ -0,0 +1,140 @@
+package routes
+
+import (
+	db "api/db"
+	config "api/utils/config"
+	gameclock "api/utils/gameclock"
+	metrics "api/utils/metrics"
+	middleware "api/utils/middleware"
+	ratelimit "api/utils/ratelimit"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Server serves the API from a Store
+type Server struct {
+	Store  db.Store
+	Config config.Config
+	Clock  *gameclock.Clock
+
+	// Buckets hold the rate limiting tokens, in memory unless replaced by
+	// buckets shared between replicas
+	Buckets ratelimit.Buckets
+}
+
+func NewServer(store db.Store, cfg config.Config, clock *gameclock.Clock) *Server {
+	return &Server{Store: store, Config: cfg, Clock: clock, Buckets: ratelimit.NewMemory()}
+}
+
+// methods maps the HTTP methods of a route to their handler
+type methods map[string]http.HandlerFunc
+
+// publicCORS lets the front, and the other allowed origins, call the
+// public routes from the browser
+func (s *Server) publicCORS() middleware.CORSPolicy {
+	return middleware.CORSPolicy{
+		Origins:       s.Config.CORSOrigins,
+		Credentials:   s.Config.CORSCredentials,
+		Headers:       []string{"Content-Type", "Authorization", "X-Requested-With", SessionIDHeader},
+		ExposeHeaders: []string{SessionIDHeader, middleware.RequestIDHeader, "Retry-After"},
+		MaxAge:        10 * time.Minute,
+	}
+}
+
+// Routes registers every route, each one tagged with its pattern for the
+// access log. A route answers OPTIONS with its Allow header, and 405 to the
+// other methods it does not handle. Public routes follow the configured CORS policy; the
+// private and operational ones are same-origin only, so a browser on another
+// site cannot call them with an admin's credentials.
+func (s *Server) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	handle := func(path string, policy middleware.CORSPolicy, handlers methods) {
+		allow := make([]string, 0, len(handlers)+1)
+		for method := range handlers {
+			allow = append(allow, method)
+		}
+		// A GET pattern serves HEAD as well
+		if handlers[http.MethodGet] != nil {
+			allow = append(allow, http.MethodHead)
+		}
+		sort.Strings(allow)
+
+		cors := middleware.CORS(policy, allow)
+		for method, handler := range handlers {
+			mux.Handle(method+" "+path, middleware.Chain(handler, middleware.Route(path), cors))
+		}
+		mux.Handle(path, middleware.Chain(otherMethods(allow), middleware.Route(path), cors))
+	}
+	public := s.publicCORS()
+	sameOrigin := middleware.CORSPolicy{}
+
+	mux.HandleFunc("/", s.NotFound)
+	handle("/health", sameOrigin, methods{http.MethodGet: s.HealthHandler})
+	handle("/livez", sameOrigin, methods{http.MethodGet: s.Livez})
+	handle("/readyz", sameOrigin, methods{http.MethodGet: s.Readyz})
+	handle("/public/v1/persons", public, methods{http.MethodGet: s.GetPersons})
+	handle("/public/v1/persons/{id}/image", public, methods{http.MethodGet: s.GetPersonImage})
+	handle("/public/v1/guess/person/submit", public, methods{http.MethodPost: s.limited("submit", s.GuessPersonOfTheDay)})
+	handle("/public/v1/guess/person/hint", public, methods{http.MethodGet: s.limited("hint", s.GetHint)})
+	handle("/public/v1/guess/person/yesterday", public, methods{http.MethodGet: s.GetPersonOfYesterday})
+	handle("/public/v1/guess/id", public, methods{http.MethodGet: s.GetGuessID})
+	handle("/public/v1/guess/history", public, methods{http.MethodGet: s.GetGuessHistory})
+	handle("/public/v1/leaderboard", public, methods{http.MethodGet: s.GetLeaderboard})
+	handle("/public/v1/auth/register", public, methods{http.MethodPost: s.Register})
+	handle("/public/v1/auth/login", public, methods{http.MethodPost: s.Login})
+
+	handle("/private/v1/guess/persons", sameOrigin, methods{http.MethodGet: s.GetPersonsOfTheDay})
+	handle("/private/v1/guess/person/today", sameOrigin, methods{http.MethodGet: s.GetPersonOfTheDay})
+	handle("/private/v1/guess/person/create", sameOrigin, methods{
+		http.MethodPost: s.CreatePersonOfTheDay,
+		// Rotating used to be a GET, kept until every caller has moved on
+		http.MethodGet: deprecated(http.MethodPost, s.CreatePersonOfTheDay),
+	})
+	handle("/private/v1/guess/schedule", sameOrigin, methods{http.MethodGet: s.GetSchedule})
+	handle("/private/v1/persons", sameOrigin, methods{http.MethodGet: s.ListPersons, http.MethodPost: s.CreatePerson})
+	handle("/private/v1/persons/{id}", sameOrigin, methods{http.MethodGet: s.GetPerson, http.MethodPut: s.UpdatePerson, http.MethodDelete: s.DeletePerson})
+	handle("/private/v1/persons/{id}/restore", sameOrigin, methods{http.MethodPost: s.RestorePerson})
+	handle("/private/v1/persons/{id}/image", sameOrigin, methods{http.MethodPost: s.UploadPortrait, http.MethodDelete: s.DeletePortrait})
+	handle("/private/v1/keys", sameOrigin, methods{http.MethodGet: s.ListAPIKeys, http.MethodPost: s.CreateAPIKey})
+	handle("/private/v1/keys/{id}", sameOrigin, methods{http.MethodDelete: s.RevokeAPIKey})
+
+	return mux
+}
+
+// MetricsRoutes serves the Prometheus metrics, meant for a listener that is
+// not exposed publicly
+func MetricsRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	return mux
+}
+
+// otherMethods answers the methods a route does not handle: OPTIONS lists
+// the allowed methods, preflights having been answered by the CORS
+// middleware, and anything else is not allowed
+func otherMethods(methods []string) http.HandlerFunc {
+	allowed := append(append([]string(nil), methods...), http.MethodOptions)
+	sort.Strings(allowed)
+	allow := strings.Join(allowed, ", ")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeMethodNotAllowed(w, r, allow)
+	}
+}
+
+// deprecated serves a method kept for older callers, warning them to move
+// to the replacement method
+func deprecated(replacement string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Warning", `299 - "`+r.Method+` is deprecated, use `+replacement+` instead"`)
+		handler(w, r)
+	}
+}
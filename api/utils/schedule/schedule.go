@@ -0,0 +1,103 @@
+package schedule
+
+import (
+	persons "api/struct"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+type Pick struct {
+	Date   string         `json:"date"`
+	Person persons.Person `json:"person"`
+}
+
+// rank derives a deterministic 64-bit value from the seed, the cycle and the
+// person, so the schedule does not depend on math/rand internals
+func rank(seed string, cycle int, id string) uint64 {
+	mac := hmac.New(sha256.New, []byte(seed))
+	if _, err := fmt.Fprintf(mac, "%d:%s", cycle, id); err != nil {
+		panic(err) // hash writes never fail
+	}
+	return binary.BigEndian.Uint64(mac.Sum(nil))
+}
+
+// Next picks the person of date and returns the cycle with that pick
+// recorded. Within a cycle persons come in the order of their seeded rank,
+// skipping the ones already picked, so adding or deleting persons never
+// makes anyone come back before the cycle is over. A new cycle starts once
+// everyone left in the pool was picked, and never opens with the person who
+// closed the previous one. Picks on or after date are replaced, so rotating
+// a day again gives the same person.
+func Next(seed string, pool []persons.Person, cycle persons.ScheduleCycle, date string) (persons.Person, persons.ScheduleCycle, error) {
+	if len(pool) == 0 {
+		return persons.Person{}, cycle, fmt.Errorf("no persons available to schedule")
+	}
+
+	var kept []persons.CyclePick
+	for _, pick := range cycle.Picks {
+		if pick.Date < date {
+			kept = append(kept, pick)
+		}
+	}
+	cycle.Picks = kept
+
+	picked := map[string]bool{}
+	for _, pick := range cycle.Picks {
+		picked[pick.PersonID] = true
+	}
+	remaining := unpicked(pool, picked)
+
+	if len(remaining) == 0 {
+		last := cycle.Picks[len(cycle.Picks)-1].PersonID
+		cycle = persons.ScheduleCycle{Number: cycle.Number + 1, Previous: last}
+		remaining = pool
+	}
+
+	// The first day of a cycle, rotated again or not, skips the person of the
+	// day before unless nobody else is left
+	if len(cycle.Picks) == 0 {
+		if others := unpicked(remaining, map[string]bool{cycle.Previous: true}); len(others) > 0 {
+			remaining = others
+		}
+	}
+
+	next := remaining[0]
+	for _, person := range remaining[1:] {
+		if rank(seed, cycle.Number, person.ID) < rank(seed, cycle.Number, next.ID) {
+			next = person
+		}
+	}
+
+	cycle.Picks = append(append([]persons.CyclePick(nil), cycle.Picks...), persons.CyclePick{Date: date, PersonID: next.ID})
+	return next, cycle, nil
+}
+
+func unpicked(pool []persons.Person, picked map[string]bool) []persons.Person {
+	var remaining []persons.Person
+	for _, person := range pool {
+		if !picked[person.ID] {
+			remaining = append(remaining, person)
+		}
+	}
+	return remaining
+}
+
+// Upcoming returns the schedule for the given number of days starting at
+// from, continuing the cycle as long as the pool does not change
+func Upcoming(seed string, pool []persons.Person, cycle persons.ScheduleCycle, from time.Time, days int) ([]Pick, error) {
+	picks := make([]Pick, 0, days)
+	for i := 0; i < days; i++ {
+		date := from.AddDate(0, 0, i).Format("2006-01-02")
+
+		var person persons.Person
+		var err error
+		if person, cycle, err = Next(seed, pool, cycle, date); err != nil {
+			return nil, err
+		}
+		picks = append(picks, Pick{Date: date, Person: person})
+	}
+	return picks, nil
+}
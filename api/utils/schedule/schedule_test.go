@@ -0,0 +1,177 @@
+package schedule_test
+
+import (
+	persons "api/struct"
+	schedule "api/utils/schedule"
+	"fmt"
+	"testing"
+	"time"
+)
+
+const seed = "test-seed"
+
+var start = time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)
+
+func pool(size int) []persons.Person {
+	var pool []persons.Person
+	for i := 0; i < size; i++ {
+		pool = append(pool, persons.Person{ID: fmt.Sprintf("person-%02d", i)})
+	}
+	return pool
+}
+
+func day(offset int) string {
+	return start.AddDate(0, 0, offset).Format("2006-01-02")
+}
+
+// run picks the persons of the given number of days, calling change before
+// each day so the test can edit the pool
+func run(t *testing.T, seed string, days int, change func(day int, pool []persons.Person) []persons.Person) []string {
+	t.Helper()
+	var cycle persons.ScheduleCycle
+	var current []persons.Person
+	var ids []string
+	for i := 0; i < days; i++ {
+		current = change(i, current)
+
+		var person persons.Person
+		var err error
+		if person, cycle, err = schedule.Next(seed, current, cycle, day(i)); err != nil {
+			t.Fatalf("Next(%s) error = %v", day(i), err)
+		}
+		ids = append(ids, person.ID)
+	}
+	return ids
+}
+
+func fixed(size int) func(int, []persons.Person) []persons.Person {
+	return func(int, []persons.Person) []persons.Person { return pool(size) }
+}
+
+func TestNextIsDeterministic(t *testing.T) {
+	first := run(t, seed, 20, fixed(7))
+	second := run(t, seed, 20, fixed(7))
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("same seed gave %v then %v", first, second)
+	}
+
+	if other := run(t, "other-seed", 20, fixed(7)); fmt.Sprint(other) == fmt.Sprint(first) {
+		t.Errorf("another seed gave the same schedule %v", other)
+	}
+}
+
+func TestNextNeverRepeatsWithinACycle(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 10} {
+		t.Run(fmt.Sprintf("%d persons", size), func(t *testing.T) {
+			ids := run(t, seed, 3*size, fixed(size))
+			for cycle := 0; cycle < 3; cycle++ {
+				seen := map[string]bool{}
+				for _, id := range ids[cycle*size : (cycle+1)*size] {
+					if seen[id] {
+						t.Errorf("cycle %d picked %s twice: %v", cycle, id, ids)
+					}
+					seen[id] = true
+				}
+			}
+			// Nobody plays two days in a row across a cycle boundary
+			for i := 1; i < len(ids) && size > 1; i++ {
+				if ids[i] == ids[i-1] {
+					t.Errorf("day %d repeats %s: %v", i, ids[i], ids)
+				}
+			}
+		})
+	}
+}
+
+func TestNextSurvivesPoolChanges(t *testing.T) {
+	t.Run("person added mid-cycle", func(t *testing.T) {
+		ids := run(t, seed, 6, func(day int, current []persons.Person) []persons.Person {
+			if day == 3 {
+				return pool(6)
+			}
+			if current == nil {
+				return pool(5)
+			}
+			return current
+		})
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("%s picked twice in one cycle: %v", id, ids)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("person deleted mid-cycle", func(t *testing.T) {
+		ids := run(t, seed, 5, func(day int, current []persons.Person) []persons.Person {
+			if day == 2 {
+				return append(append([]persons.Person(nil), current[:4]...), current[5:]...)
+			}
+			if current == nil {
+				return pool(6)
+			}
+			return current
+		})
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				t.Errorf("%s picked twice in one cycle: %v", id, ids)
+			}
+			seen[id] = true
+		}
+	})
+}
+
+func TestNextReplacesTheSameDay(t *testing.T) {
+	first, cycle, err := schedule.Next(seed, pool(5), persons.ScheduleCycle{}, day(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, cycle, err := schedule.Next(seed, pool(5), cycle, day(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != first.ID || len(cycle.Picks) != 1 {
+		t.Errorf("rotating again gave %s with %d picks, want %s with 1", again.ID, len(cycle.Picks), first.ID)
+	}
+}
+
+func TestNextReplacesTheFirstDayOfACycle(t *testing.T) {
+	for _, seed := range []string{"aa", seed, "other-seed"} {
+		var cycle persons.ScheduleCycle
+		var yesterday string
+		for i := 0; i < 6; i++ {
+			first, next, err := schedule.Next(seed, pool(2), cycle, day(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Rotating the day again, as the create route does, keeps the pick
+			again, _, err := schedule.Next(seed, pool(2), next, day(i))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again.ID != first.ID || again.ID == yesterday {
+				t.Errorf("seed %s, %s: picked %s then %s, yesterday was %s", seed, day(i), first.ID, again.ID, yesterday)
+			}
+			cycle, yesterday = next, first.ID
+		}
+	}
+}
+
+func TestUpcomingFollowsNext(t *testing.T) {
+	picks, err := schedule.Upcoming(seed, pool(4), persons.ScheduleCycle{}, start, 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := run(t, seed, 8, fixed(4))
+	for i, pick := range picks {
+		if pick.Date != day(i) || pick.Person.ID != want[i] {
+			t.Errorf("Upcoming()[%d] = %s %s, want %s %s", i, pick.Date, pick.Person.ID, day(i), want[i])
+		}
+	}
+
+	if _, err := schedule.Upcoming(seed, nil, persons.ScheduleCycle{}, start, 1); err == nil {
+		t.Error("Upcoming() succeeded without persons")
+	}
+}
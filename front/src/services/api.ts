@@ -1,53 +1,114 @@
-import type { Person, GuessResult } from '@/types/person';
+import type { Person, PublicPerson, GuessResult, GuessHistoryResponse } from '@/types/person';
 
 // Use relative path for API calls when using nginx proxy, or full URL for direct calls
 const API_BASE_URL = 'PLACEHOLDER_API_URL'; // This will be replaced with actual API URL
 
+// Anonymous session id issued by the API on the first guess
+const SESSION_ID_HEADER = 'X-Session-ID';
+const STORAGE_KEY_SESSION_ID = 'dodle_session_id';
+
+const sessionHeaders = (): Record<string, string> => {
+  const sessionId = localStorage.getItem(STORAGE_KEY_SESSION_ID);
+  return sessionId ? { [SESSION_ID_HEADER]: sessionId } : {};
+};
+
+const storeSessionId = (response: Response) => {
+  const sessionId = response.headers.get(SESSION_ID_HEADER);
+  if (sessionId) {
+    localStorage.setItem(STORAGE_KEY_SESSION_ID, sessionId);
+  }
+};
+
+// Every API error answers with this envelope
+export interface ApiErrorResponse {
+  code: string;
+  message: string;
+  request_id?: string;
+}
+
+export class ApiError extends Error {
+  readonly status: number;
+  readonly code: string;
+  readonly requestId?: string;
+
+  constructor(status: number, code: string, message: string, requestId?: string) {
+    super(message);
+    this.name = 'ApiError';
+    this.status = status;
+    this.code = code;
+    this.requestId = requestId;
+  }
+}
+
+// Throws an ApiError carrying the API message, or the fallback when the body
+// is not the error envelope (e.g. a proxy error page)
+const ensureOk = async (response: Response, fallback: string): Promise<void> => {
+  if (response.ok) {
+    return;
+  }
+  let body: Partial<ApiErrorResponse> = {};
+  try {
+    body = await response.json();
+  } catch {
+    // Not JSON, keep the fallback message
+  }
+  throw new ApiError(
+    response.status,
+    body.code ?? 'unknown',
+    body.message ?? fallback,
+    body.request_id ?? response.headers.get('X-Request-ID') ?? undefined,
+  );
+};
+
 export const apiService = {
-  async getPersons(): Promise<{ persons: Person[] }> {
+  async getPersons(): Promise<{ persons: PublicPerson[] }> {
     const response = await fetch(`${API_BASE_URL}/public/v1/persons`);
-    if (!response.ok) {
-      throw new Error('Failed to fetch persons');
-    }
+    await ensureOk(response, 'Failed to fetch persons');
     return response.json();
   },
 
-  async submitGuess(person: Person): Promise<GuessResult> {
+  async submitGuess(person: PublicPerson): Promise<GuessResult> {
     const response = await fetch(`${API_BASE_URL}/public/v1/guess/person/submit`, {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json',
+        ...sessionHeaders(),
       },
-      body: JSON.stringify(person),
+      body: JSON.stringify({ id: person.id }),
     });
     
-    if (!response.ok) {
-      throw new Error('Failed to submit guess');
-    }
+    await ensureOk(response, 'Failed to submit guess');
+    storeSessionId(response);
     return response.json();
   },
 
-  async getHint(): Promise<string> {
-    const response = await fetch(`${API_BASE_URL}/public/v1/guess/person/hint`);
-    if (!response.ok) {
-      throw new Error('Failed to get hint');
-    }
+  async getGuessHistory(): Promise<GuessHistoryResponse> {
+    const response = await fetch(`${API_BASE_URL}/public/v1/guess/history`, {
+      headers: sessionHeaders(),
+    });
+    await ensureOk(response, 'Failed to fetch guess history');
     return response.json();
   },
 
+  async getHint(): Promise<string> {
+    const response = await fetch(`${API_BASE_URL}/public/v1/guess/person/hint`, {
+      headers: sessionHeaders(),
+    });
+    // A locked hint answers 403 hint_locked, the message says how many guesses are left
+    await ensureOk(response, 'Failed to get hint');
+    const data = await response.json();
+    return data.hint;
+  },
+
   async getYesterdaysPerson(): Promise<Person> {
     const response = await fetch(`${API_BASE_URL}/public/v1/guess/person/yesterday`);
-    if (!response.ok) {
-      throw new Error('Failed to fetch yesterday\'s person');
-    }
+    await ensureOk(response, 'Failed to fetch yesterday\'s person');
     return response.json();
   },
   
   async getGuessID(): Promise<string> {
     const response = await fetch(`${API_BASE_URL}/public/v1/guess/id`);
-    if (!response.ok) {
-      throw new Error('Failed to fetch guess ID');
-    }
+    await ensureOk(response, 'Failed to fetch guess ID');
     const data = await response.json();
     return data.id; // Extract the ID from the wrapper object
   },
@@ -1,4 +1,5 @@
 export interface Person {
+  id: string;
   firstname: string;
   lastname: string;
   gender: string;
@@ -8,9 +9,25 @@ export interface Person {
   hint: string;
 }
 
+export type Verdict = 'correct' | 'partial' | 'wrong' | 'higher' | 'lower';
+
+export type HintField = 'firstname' | 'lastname' | 'gender' | 'type' | 'workplace';
+
+export type Hints = Record<HintField, Verdict>;
+
+// What the API exposes about every guessable person
+export interface PublicPerson {
+  id: string;
+  firstname: string;
+  lastname: string;
+  image: string;
+}
+
 export interface GuessResult {
   correct: boolean;
+  guessed?: Person;
   person: Person;
+  hints?: Hints;
 }
 
 export interface GuessHistory {
@@ -18,3 +35,18 @@ export interface GuessHistory {
   result: GuessResult;
   guessedPerson: Person;
 }
+
+export interface StoredGuess {
+  id: string;
+  date: string;
+  guessed: Person;
+  result: Person;
+  hints: Hints;
+  correct: boolean;
+  created_at: string;
+}
+
+export interface GuessHistoryResponse {
+  date: string;
+  guesses: StoredGuess[];
+}